@@ -50,9 +50,17 @@ func createSampleFlow(name string) *flow.Flow {
 			DefaultProvider: "github_playground_openai",
 			DefaultModel:    "openai/gpt-4o-mini",
 		},
+		Inputs: []flow.FlowInput{
+			{
+				Name:        "user_input",
+				Description: "The message to respond to",
+				Required:    true,
+			},
+		},
 		Nodes: []flow.Node{
 			{
 				ID:       "process",
+				Type:     flow.NodeTypeLLM,
 				Provider: "github_playground_openai",
 				Model:    "openai/gpt-4o-mini",
 				Inputs: []flow.Input{
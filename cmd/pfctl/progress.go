@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/broderick/prompt-flow/pkg/executor"
+	"github.com/broderick/prompt-flow/pkg/flow"
+)
+
+// progressPrinter prints a line for each node as the flow runs
+type progressPrinter struct {
+	executor.NopObserver
+
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func newProgressPrinter(out io.Writer) *progressPrinter {
+	return &progressPrinter{out: out}
+}
+
+func (p *progressPrinter) printf(format string, args ...any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintf(p.out, format, args...)
+}
+
+func (p *progressPrinter) NodeStart(ctx context.Context, node *flow.Node, inputs map[string]any) {
+	p.printf("  ... %s started\n", node.ID)
+}
+
+func (p *progressPrinter) NodeEnd(ctx context.Context, node *flow.Node, result *flow.NodeResult) {
+	switch {
+	case result.Reused:
+		p.printf("  =   %s reused (%s in previous run)\n", node.ID, result.Status)
+	case result.Status == flow.NodeStatusSucceeded && result.Cached:
+		p.printf("  ✓   %s succeeded from the response cache\n", node.ID)
+	case result.Status == flow.NodeStatusSucceeded:
+		p.printf("  ✓   %s succeeded (%v)\n", node.ID, result.Duration.Round(time.Millisecond))
+	case result.Status == flow.NodeStatusSkipped:
+		p.printf("  -   %s skipped: %s\n", node.ID, result.SkipReason)
+	default:
+		p.printf("  ✗   %s failed: %s\n", node.ID, result.Error)
+	}
+}
+
+func (p *progressPrinter) Retry(ctx context.Context, node *flow.Node, failed flow.Attempt, delay time.Duration) {
+	p.printf("  ↻   %s attempt %d failed (%s), retrying in %v\n", node.ID, failed.Number, failed.ErrorClass, delay.Round(time.Millisecond))
+}
@@ -17,6 +17,19 @@ type TestCmd struct {
 	FlowFile string        `arg:"" help:"Path to flow definition file"`
 	Input    []string      `short:"i" help:"Input values as key=value pairs"`
 	Timeout  time.Duration `short:"t" default:"5m" help:"Execution timeout"`
+	Quiet    bool          `short:"q" help:"Don't print node progress while the flow runs"`
+	SaveRun  string        `help:"Save the execution result to this JSON file, for use with --from-run"`
+	FromRun  string        `help:"Previous run file to reuse node results from, see --start-at"`
+	StartAt  string        `help:"Node to re-run from; it and its descendants run again, other nodes reuse --from-run"`
+	Cache    bool          `help:"Reuse cached responses for provider calls made before, and cache new ones"`
+	CacheDir string        `help:"Directory for cached provider responses, with --cache (default: user cache directory)"`
+	CacheTTL time.Duration `name:"cache-ttl" default:"24h" help:"How long cached responses are reused, with --cache (0 = forever)"`
+	Record   string        `help:"Record every provider call to this cassette file"`
+	Replay   string        `help:"Answer provider calls from this cassette file instead of calling providers"`
+
+	MaxCost         float64 `help:"Stop the run once it has cost this many USD, overriding config.budget"`
+	MaxInputTokens  int     `help:"Stop the run once it has sent this many prompt tokens, overriding config.budget"`
+	MaxOutputTokens int     `help:"Stop the run once it has received this many completion tokens, overriding config.budget"`
 }
 
 func (c *TestCmd) Run() error {
@@ -31,6 +44,18 @@ func (c *TestCmd) Run() error {
 		return fmt.Errorf("validation failed: %w", err)
 	}
 
+	if (c.FromRun == "") != (c.StartAt == "") {
+		return fmt.Errorf("--from-run and --start-at must be used together")
+	}
+	if c.Record != "" && c.Replay != "" {
+		return fmt.Errorf("--record and --replay cannot be used together")
+	}
+	// Cassettes record and replay real provider calls, so they bypass the cache
+	if c.Cache && (c.Record != "" || c.Replay != "") {
+		return fmt.Errorf("--cache cannot be used with --record or --replay")
+	}
+	c.applyBudget(f)
+
 	// Parse inputs
 	inputs := make(map[string]any)
 	for _, arg := range c.Input {
@@ -47,23 +72,100 @@ func (c *TestCmd) Run() error {
 
 	// Create provider registry and executor
 	registry := providers.NewRegistry().WithDefaultProviders()
-	exec := executor.New(registry)
+	var recorder *providers.Recorder
+	switch {
+	case c.Replay != "":
+		cassette, loadErr := providers.LoadCassette(c.Replay)
+		if loadErr != nil {
+			return loadErr
+		}
+		replayer, replayErr := providers.NewReplayer(cassette)
+		if replayErr != nil {
+			return fmt.Errorf("failed to load cassette: %w", replayErr)
+		}
+		registry.Use(replayer.Wrap)
+	case c.Record != "":
+		recorder = providers.NewRecorder()
+		registry.Use(recorder.Wrap)
+	}
+	if c.Cache {
+		cacheDir := c.CacheDir
+		if cacheDir == "" {
+			if cacheDir, err = providers.DefaultCacheDir(); err != nil {
+				return err
+			}
+		}
+		registry.WithCache(providers.NewCache(cacheDir, c.CacheTTL))
+	}
+	var opts []executor.Option
+	if !c.Quiet {
+		opts = append(opts, executor.WithObserver(newProgressPrinter(os.Stdout)))
+	}
+	exec := executor.New(registry, opts...)
 
 	// Execute with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
 	defer cancel()
 
-	fmt.Printf("Executing flow '%s'...\n\n", f.Name)
+	fmt.Printf("Executing flow '%s'...\n", f.Name)
 
-	result, err := exec.Execute(ctx, f, inputs)
+	var result *flow.ExecutionResult
+	if c.FromRun != "" {
+		previous, loadErr := flow.LoadRun(c.FromRun)
+		if loadErr != nil {
+			return fmt.Errorf("failed to load previous run: %w", loadErr)
+		}
+		result, err = exec.ExecuteFrom(ctx, f, inputs, previous, c.StartAt)
+	} else {
+		result, err = exec.Execute(ctx, f, inputs)
+	}
+	fmt.Println()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Execution failed: %v\n\n", err)
 	}
 
 	printExecutionResult(result)
+
+	if c.SaveRun != "" {
+		if saveErr := flow.SaveRun(result, c.SaveRun); saveErr != nil {
+			return fmt.Errorf("failed to save run: %w", saveErr)
+		}
+		fmt.Printf("\nRun saved to %s\n", c.SaveRun)
+	}
+
+	if recorder != nil {
+		cassette := recorder.Cassette()
+		if saveErr := cassette.Save(c.Record); saveErr != nil {
+			return saveErr
+		}
+		fmt.Printf("\nRecorded %d provider calls to %s\n", len(cassette.Interactions), c.Record)
+	}
+
 	return err
 }
 
+// applyBudget overrides the flow's budget with any limits given as flags
+func (c *TestCmd) applyBudget(f *flow.Flow) {
+	if c.MaxCost <= 0 && c.MaxInputTokens <= 0 && c.MaxOutputTokens <= 0 {
+		return
+	}
+
+	budget := flow.Budget{}
+	if f.Config.Budget != nil {
+		budget = *f.Config.Budget
+	}
+	if c.MaxCost > 0 {
+		budget.MaxCost = c.MaxCost
+	}
+	if c.MaxInputTokens > 0 {
+		budget.MaxInputTokens = c.MaxInputTokens
+	}
+	if c.MaxOutputTokens > 0 {
+		budget.MaxOutputTokens = c.MaxOutputTokens
+	}
+	f.Config.Budget = &budget
+}
+
 func printExecutionResult(result *flow.ExecutionResult) {
 	fmt.Printf("=== Execution Result ===\n")
 	fmt.Printf("Flow: %s\n", result.FlowName)
@@ -75,25 +177,52 @@ func printExecutionResult(result *flow.ExecutionResult) {
 	}
 
 	fmt.Printf("\n=== Node Results ===\n")
-	totalTokens := 0
-	totalCost := 0.0
 
 	for i, nodeResult := range result.NodeResults {
 		fmt.Printf("\n[%d] Node: %s\n", i+1, nodeResult.NodeID)
-		fmt.Printf("    Success: %v\n", nodeResult.Success)
+		if nodeResult.Reused {
+			fmt.Printf("    Status: %s (reused from previous run)\n", nodeResult.Status)
+		} else {
+			fmt.Printf("    Status: %s\n", nodeResult.Status)
+		}
 		fmt.Printf("    Duration: %v\n", nodeResult.Duration)
 
+		if nodeResult.Provider != "" {
+			if nodeResult.Cached {
+				fmt.Printf("    Provider: %s/%s (cached)\n", nodeResult.Provider, nodeResult.Model)
+			} else {
+				fmt.Printf("    Provider: %s/%s\n", nodeResult.Provider, nodeResult.Model)
+			}
+		}
+
+		if nodeResult.Throttled >= time.Millisecond {
+			fmt.Printf("    Throttled: %v\n", nodeResult.Throttled.Round(time.Millisecond))
+		}
+
+		if nodeResult.SkipReason != "" {
+			fmt.Printf("    Skipped: %s\n", nodeResult.SkipReason)
+		}
+
 		if nodeResult.Error != "" {
 			fmt.Printf("    Error: %s\n", nodeResult.Error)
 		}
 
+		if len(nodeResult.Violations) > 0 {
+			fmt.Printf("    Schema violations:\n")
+			for _, violation := range nodeResult.Violations {
+				fmt.Printf("      - %s\n", violation)
+			}
+		}
+
+		if nodeResult.HandledBy != "" {
+			fmt.Printf("    Handled by: %s\n", nodeResult.HandledBy)
+		}
+
 		if nodeResult.Metrics.InputTokens > 0 {
 			fmt.Printf("    Tokens: %d (input: %d, output: %d)\n",
 				nodeResult.Metrics.InputTokens+nodeResult.Metrics.OutputTokens,
 				nodeResult.Metrics.InputTokens,
 				nodeResult.Metrics.OutputTokens)
-
-			totalTokens += nodeResult.Metrics.InputTokens
 		}
 
 		if nodeResult.Metrics.InputCost > 0 {
@@ -101,8 +230,27 @@ func printExecutionResult(result *flow.ExecutionResult) {
 				nodeResult.Metrics.InputCost+nodeResult.Metrics.OutputCost,
 				nodeResult.Metrics.InputCost,
 				nodeResult.Metrics.OutputCost)
+		}
+
+		if len(nodeResult.Attempts) > 1 {
+			fmt.Printf("    Attempts:\n")
+			for _, attempt := range nodeResult.Attempts {
+				status := "ok"
+				if attempt.Error != "" {
+					status = fmt.Sprintf("%s: %s", attempt.ErrorClass, attempt.Error)
+				}
+				fmt.Printf("      #%d %s/%s (%v) %s\n", attempt.Number, attempt.Provider, attempt.Model, attempt.Latency, status)
+			}
+		}
 
-			totalCost += nodeResult.Metrics.InputCost + nodeResult.Metrics.OutputCost
+		if len(nodeResult.Items) > 0 {
+			fmt.Printf("    Items:\n")
+			for _, item := range nodeResult.Items {
+				fmt.Printf("      %s: %s (%v)\n", item.NodeID, item.Status, item.Duration)
+				if item.Error != "" {
+					fmt.Printf("        Error: %s\n", item.Error)
+				}
+			}
 		}
 
 		if len(nodeResult.Outputs) > 0 {
@@ -113,10 +261,17 @@ func printExecutionResult(result *flow.ExecutionResult) {
 		}
 	}
 
+	if len(result.Errors) > 0 {
+		fmt.Printf("\n=== Failed Nodes ===\n")
+		for _, nodeErr := range result.Errors {
+			fmt.Printf("%s: %s\n", nodeErr.NodeID, nodeErr.Error)
+		}
+	}
+
 	fmt.Printf("\n=== Summary ===\n")
-	fmt.Printf("Total Tokens: %d\n", totalTokens)
+	fmt.Printf("Total Tokens: %d\n", result.Metrics.InputTokens+result.Metrics.OutputTokens)
 
-	if totalCost > 0 {
+	if totalCost := result.Metrics.InputCost + result.Metrics.OutputCost; totalCost > 0 {
 		fmt.Printf("Total Cost: $%.6f\n", totalCost)
 	}
 
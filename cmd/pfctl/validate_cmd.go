@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/broderick/prompt-flow/pkg/flow"
 )
@@ -24,6 +25,9 @@ func (c *ValidateCmd) Run() error {
 
 	fmt.Printf("✓ Flow '%s' is valid\n", f.Name)
 	fmt.Printf("  - %d nodes\n", len(f.Nodes))
+	if names := f.InputNames(); len(names) > 0 {
+		fmt.Printf("  - Inputs: %s\n", strings.Join(names, ", "))
+	}
 	fmt.Printf("  - Default provider: %s\n", f.Config.DefaultProvider)
 	fmt.Printf("  - Default model: %s\n", f.Config.DefaultModel)
 
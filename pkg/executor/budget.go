@@ -0,0 +1,148 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/broderick/prompt-flow/pkg/flow"
+	"github.com/broderick/prompt-flow/pkg/providers"
+)
+
+// Budget limits, as named in BudgetExceededError
+const (
+	BudgetMaxCost         = "max_cost"
+	BudgetMaxInputTokens  = "max_input_tokens"
+	BudgetMaxOutputTokens = "max_output_tokens"
+)
+
+// BudgetExceededError is returned when a run is stopped by its budget, either because
+// a limit has been reached or because the next llm call is estimated to exceed it
+type BudgetExceededError struct {
+	NodeID   string  // Node that was about to run or call its provider
+	Limit    string  // The limit reached, one of the Budget constants
+	Max      float64 // Value of the limit
+	Spent    float64 // Amount spent by the run so far
+	Estimate float64 // For an estimated llm call, its estimated cost in USD
+}
+
+func (e *BudgetExceededError) Error() string {
+	if e.Estimate > 0 {
+		return fmt.Sprintf("budget exceeded at node %s: estimated call cost $%.4f would take spent $%.4f over %s $%.4f",
+			e.NodeID, e.Estimate, e.Spent, e.Limit, e.Max)
+	}
+	if e.Limit == BudgetMaxCost {
+		return fmt.Sprintf("budget exceeded at node %s: spent $%.4f of %s $%.4f", e.NodeID, e.Spent, e.Limit, e.Max)
+	}
+	return fmt.Sprintf("budget exceeded at node %s: used %.0f of %s %.0f", e.NodeID, e.Spent, e.Limit, e.Max)
+}
+
+// isBudgetExceeded reports whether err was caused by a run exceeding its budget
+func isBudgetExceeded(err error) bool {
+	var budgetErr *BudgetExceededError
+	return errors.As(err, &budgetErr)
+}
+
+// budget tracks what a run has spent against its limits. It is shared by every node
+// of the run, including map items and subflows, through the run's context. A nil
+// budget has no limits.
+type budget struct {
+	limits flow.Budget
+
+	mu    sync.Mutex
+	spent flow.NodeMetrics
+}
+
+type budgetKey struct{}
+
+// withBudget returns a context carrying the run's budget. A run nested in another,
+// such as a subflow, keeps the budget of the outer run.
+func withBudget(ctx context.Context, limits *flow.Budget) context.Context {
+	if limits == nil || budgetFrom(ctx) != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, budgetKey{}, &budget{limits: *limits})
+}
+
+// budgetFrom returns the budget of the run ctx belongs to, or nil if it has none
+func budgetFrom(ctx context.Context) *budget {
+	b, _ := ctx.Value(budgetKey{}).(*budget)
+	return b
+}
+
+// add records the usage of a completed provider call
+func (b *budget) add(m flow.NodeMetrics) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	addMetrics(&b.spent, m)
+}
+
+// check returns a BudgetExceededError if the run has reached any of its limits
+func (b *budget) check(nodeID string) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	cost := b.spent.InputCost + b.spent.OutputCost
+	switch {
+	case b.limits.MaxCost > 0 && cost >= b.limits.MaxCost:
+		return &BudgetExceededError{NodeID: nodeID, Limit: BudgetMaxCost, Max: b.limits.MaxCost, Spent: cost}
+	case b.limits.MaxInputTokens > 0 && b.spent.InputTokens >= b.limits.MaxInputTokens:
+		return &BudgetExceededError{
+			NodeID: nodeID,
+			Limit:  BudgetMaxInputTokens,
+			Max:    float64(b.limits.MaxInputTokens),
+			Spent:  float64(b.spent.InputTokens),
+		}
+	case b.limits.MaxOutputTokens > 0 && b.spent.OutputTokens >= b.limits.MaxOutputTokens:
+		return &BudgetExceededError{
+			NodeID: nodeID,
+			Limit:  BudgetMaxOutputTokens,
+			Max:    float64(b.limits.MaxOutputTokens),
+			Spent:  float64(b.spent.OutputTokens),
+		}
+	}
+
+	return nil
+}
+
+// checkCall checks the limits before a provider call. When the model's pricing is
+// known, the call's worst-case cost is estimated from its prompt and max_tokens, or
+// the providers' default limit, and the call is refused if it could take the run over
+// max_cost.
+func (b *budget) checkCall(nodeID, providerName string, req providers.CompletionRequest) error {
+	if b == nil {
+		return nil
+	}
+	if err := b.check(nodeID); err != nil || b.limits.MaxCost <= 0 {
+		return err
+	}
+
+	inputCost, outputCost, ok := providers.EstimateCost(providerName, req.Model, providers.EstimateTokens(req.Text()), req.MaxTokens())
+	if !ok {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	estimate := inputCost + outputCost
+	cost := b.spent.InputCost + b.spent.OutputCost
+	if cost+estimate > b.limits.MaxCost {
+		return &BudgetExceededError{
+			NodeID:   nodeID,
+			Limit:    BudgetMaxCost,
+			Max:      b.limits.MaxCost,
+			Spent:    cost,
+			Estimate: estimate,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/broderick/prompt-flow/pkg/flow"
+	"github.com/broderick/prompt-flow/pkg/providers"
+)
+
+// budgetFlow calls a model with known pricing, so calls are estimated before they are sent
+const budgetFlow = `
+version: "1.0"
+name: budget
+config:
+  default_provider: anthropic
+  default_model: claude-3-opus-20240229
+nodes:
+  - {id: outline, inputs: [], prompt: hello, outputs: [{name: text}]}
+  - {id: draft, inputs: [{name: outline, from: outline.text}], prompt: "draft {{.outline}}", outputs: [{name: text, to: output}]}
+`
+
+// pricedProvider answers as "anthropic" with a fixed usage per call and counts its calls
+type pricedProvider struct {
+	calls int
+}
+
+func (p *pricedProvider) register(registry *providers.Registry) {
+	registry.Register(&fakeProvider{name: "anthropic", complete: func(ctx context.Context, req providers.CompletionRequest) (*providers.CompletionResponse, error) {
+		p.calls++
+		return &providers.CompletionResponse{Content: req.Prompt, InputTokens: 10, OutputTokens: 5, InputCost: 0.25, OutputCost: 0.5}, nil
+	}})
+}
+
+func TestBudget(t *testing.T) {
+	tests := []struct {
+		name      string
+		budget    flow.Budget
+		maxTokens int // max_tokens setting of the nodes, 0 for none
+		wantCalls int
+		wantErr   *BudgetExceededError // with Estimate only checked to be set or not
+	}{
+		{
+			name:      "within budget",
+			budget:    flow.Budget{MaxCost: 2, MaxInputTokens: 100, MaxOutputTokens: 100},
+			wantCalls: 2,
+		},
+		{
+			name:      "stops before a node once max_cost is spent",
+			budget:    flow.Budget{MaxCost: 0.75},
+			maxTokens: 1,
+			wantCalls: 1,
+			wantErr:   &BudgetExceededError{NodeID: "draft", Limit: BudgetMaxCost, Max: 0.75, Spent: 0.75},
+		},
+		{
+			name:      "stops before a node once max_input_tokens are used",
+			budget:    flow.Budget{MaxInputTokens: 10},
+			wantCalls: 1,
+			wantErr:   &BudgetExceededError{NodeID: "draft", Limit: BudgetMaxInputTokens, Max: 10, Spent: 10},
+		},
+		{
+			name:      "stops before a node once max_output_tokens are used",
+			budget:    flow.Budget{MaxOutputTokens: 5},
+			wantCalls: 1,
+			wantErr:   &BudgetExceededError{NodeID: "draft", Limit: BudgetMaxOutputTokens, Max: 5, Spent: 5},
+		},
+		{
+			// Without max_tokens the estimate uses the providers' default of 1000
+			// completion tokens, $0.075 at $75 per million
+			name:      "stops before a call estimated over max_cost",
+			budget:    flow.Budget{MaxCost: 0.05},
+			wantCalls: 0,
+			wantErr:   &BudgetExceededError{NodeID: "outline", Limit: BudgetMaxCost, Max: 0.05, Estimate: 1},
+		},
+		{
+			// With max_tokens 100 the first call fits, and its cost stops the run
+			name:      "a lower max_tokens fits the estimate",
+			budget:    flow.Budget{MaxCost: 0.05},
+			maxTokens: 100,
+			wantCalls: 1,
+			wantErr:   &BudgetExceededError{NodeID: "draft", Limit: BudgetMaxCost, Max: 0.05, Spent: 0.75},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := parseFlow(t, budgetFlow)
+			f.Config.Budget = &tt.budget
+			if tt.maxTokens > 0 {
+				for i := range f.Nodes {
+					f.Nodes[i].Settings = map[string]any{"max_tokens": tt.maxTokens}
+				}
+			}
+
+			provider := &pricedProvider{}
+			registry := providers.NewRegistry()
+			provider.register(registry)
+
+			result, err := New(registry).Execute(context.Background(), f, nil)
+			if provider.calls != tt.wantCalls {
+				t.Errorf("provider called %d times, want %d", provider.calls, tt.wantCalls)
+			}
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("Execute: %v", err)
+				}
+				return
+			}
+
+			var budgetErr *BudgetExceededError
+			if !errors.As(err, &budgetErr) {
+				t.Fatalf("Execute error = %v, want a BudgetExceededError", err)
+			}
+			got, want := *budgetErr, *tt.wantErr
+			if (got.Estimate > 0) != (want.Estimate > 0) {
+				t.Errorf("estimate = %v, want estimated %v", got.Estimate, want.Estimate > 0)
+			}
+			got.Estimate, want.Estimate = 0, 0
+			if got != want {
+				t.Errorf("BudgetExceededError = %+v, want %+v", got, want)
+			}
+			if result.Error != err.Error() {
+				t.Errorf("result error = %q, want the budget error %q", result.Error, err)
+			}
+		})
+	}
+}
+
+func TestBudgetSkipsCacheAndCassetteHits(t *testing.T) {
+	// Record the responses once, with no budget
+	recorder := providers.NewRecorder()
+	cache := providers.NewCache(t.TempDir(), 0)
+	warm := &pricedProvider{}
+	registry := providers.NewRegistry().WithCache(cache).Use(recorder.Wrap)
+	warm.register(registry)
+	if _, err := New(registry).Execute(context.Background(), parseFlow(t, budgetFlow), nil); err != nil {
+		t.Fatalf("warming up: %v", err)
+	}
+
+	replayer, err := providers.NewReplayer(recorder.Cassette())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		middleware providers.Middleware
+	}{
+		{name: "cache", middleware: cache.Wrap},
+		{name: "cassette", middleware: replayer.Wrap},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Both calls would be refused, by their estimate and by the tokens used,
+			// if they were sent
+			f := parseFlow(t, budgetFlow)
+			f.Config.Budget = &flow.Budget{MaxCost: 0.05, MaxInputTokens: 10}
+
+			provider := &pricedProvider{}
+			registry := providers.NewRegistry().Use(tt.middleware)
+			provider.register(registry)
+
+			if _, err := New(registry).Execute(context.Background(), f, nil); err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+			if provider.calls != 0 {
+				t.Errorf("provider called %d times, want every call answered by the %s", provider.calls, tt.name)
+			}
+		})
+	}
+}
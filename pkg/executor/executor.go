@@ -3,7 +3,9 @@ package executor
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 	"text/template"
 	"time"
@@ -14,35 +16,140 @@ import (
 
 // Executor executes a flow
 type Executor struct {
-	registry *providers.Registry
+	registry       *providers.Registry
+	maxConcurrency int
+	httpClient     *http.Client
+	observers      []Observer
+	templateFuncs  template.FuncMap
+
+	// after waits out the delay before a retry, and is replaced in tests
+	after func(time.Duration) <-chan time.Time
+}
+
+// Option configures an Executor
+type Option func(*Executor)
+
+// WithMaxConcurrency limits how many nodes the executor runs at once.
+// A value of zero or less means no limit. The items of a map node are limited
+// separately and run in addition to the nodes counted here.
+func WithMaxConcurrency(n int) Option {
+	return func(e *Executor) {
+		e.maxConcurrency = n
+	}
+}
+
+// WithHTTPClient sets the client used by http nodes. Defaults to http.DefaultClient.
+func WithHTTPClient(client *http.Client) Option {
+	return func(e *Executor) {
+		e.httpClient = client
+	}
+}
+
+// WithTemplateFuncs adds functions to the templates the executor renders, on top of
+// flow.TemplateFuncs. A function with the same name as a built-in one replaces it.
+// The executor validates flows with these functions; pass them to flow.Validate with
+// flow.WithFuncs to validate flows outside the executor.
+func WithTemplateFuncs(funcs template.FuncMap) Option {
+	return func(e *Executor) {
+		if e.templateFuncs == nil {
+			e.templateFuncs = make(template.FuncMap, len(funcs))
+		}
+		for name, fn := range funcs {
+			e.templateFuncs[name] = fn
+		}
+	}
 }
 
 // New creates a new executor
-func New(registry *providers.Registry) *Executor {
-	return &Executor{
-		registry: registry,
+func New(registry *providers.Registry, opts ...Option) *Executor {
+	e := &Executor{
+		registry:   registry,
+		httpClient: http.DefaultClient,
+		after:      time.After,
+	}
+	for _, opt := range opts {
+		opt(e)
 	}
+	return e
 }
 
 // Execute runs a flow with the given inputs
 func (e *Executor) Execute(ctx context.Context, f *flow.Flow, inputs map[string]any) (*flow.ExecutionResult, error) {
+	return e.execute(ctx, f, inputs, nil)
+}
+
+// ExecuteFrom re-runs a flow starting at the node startAt. That node and every node
+// that depends on it are executed again, while all other nodes reuse their results
+// from the previous run without calling their providers. The previous run's inputs
+// are used, with any given inputs overriding them. It returns an error without a
+// result if previous is nil or startAt is empty.
+func (e *Executor) ExecuteFrom(
+	ctx context.Context,
+	f *flow.Flow,
+	inputs map[string]any,
+	previous *flow.ExecutionResult,
+	startAt string,
+) (*flow.ExecutionResult, error) {
+	if previous == nil {
+		return nil, fmt.Errorf("re-running from a node requires the previous run's result")
+	}
+	if startAt == "" {
+		return nil, fmt.Errorf("re-running from a node requires the ID of the node to start at")
+	}
+
+	merged := make(map[string]any, len(previous.Inputs)+len(inputs))
+	for name, val := range previous.Inputs {
+		merged[name] = val
+	}
+	for name, val := range inputs {
+		merged[name] = val
+	}
+
+	return e.execute(ctx, f, merged, &runStart{previous: previous, nodeID: startAt})
+}
+
+func (e *Executor) execute(
+	ctx context.Context,
+	f *flow.Flow,
+	inputs map[string]any,
+	start *runStart,
+) (*flow.ExecutionResult, error) {
 	startTime := time.Now()
 
 	result := &flow.ExecutionResult{
 		FlowName:    f.Name,
 		Success:     false,
+		Inputs:      inputs,
 		Outputs:     make(map[string]any),
 		NodeResults: []flow.NodeResult{},
 		StartTime:   startTime,
 	}
 
-	// Validate flow first
-	if err := flow.Validate(f); err != nil {
-		result.Error = fmt.Sprintf("validation failed: %v", err)
+	e.notify(func(o Observer) { o.FlowStart(ctx, f, inputs) })
+	defer e.notify(func(o Observer) { o.FlowEnd(ctx, f, result) })
+
+	// Validate flow first, along with its subflows. The run of a subflow reuses the
+	// child flow validated with its parent.
+	if !subflowsFrom(ctx).validated(f) {
+		subflows := make(runSubflows)
+		if err := flow.Validate(f, flow.WithFuncs(e.templateFuncs), flow.WithSubflows(subflows)); err != nil {
+			result.Error = fmt.Sprintf("validation failed: %v", err)
+			result.EndTime = time.Now()
+			result.Duration = time.Since(startTime)
+			return result, err
+		}
+		ctx = withSubflows(ctx, subflows)
+	}
+
+	// Check the inputs against the flow's declarations, filling in defaults
+	inputs, err := f.PrepareInputs(inputs)
+	if err != nil {
+		result.Error = fmt.Sprintf("invalid inputs: %v", err)
 		result.EndTime = time.Now()
 		result.Duration = time.Since(startTime)
 		return result, err
 	}
+	result.Inputs = inputs
 
 	// Build execution order using topological sort
 	execOrder, err := e.topologicalSort(f)
@@ -53,34 +160,77 @@ func (e *Executor) Execute(ctx context.Context, f *flow.Flow, inputs map[string]
 		return result, err
 	}
 
-	// Storage for node outputs
-	nodeOutputs := make(map[string]map[string]any) // nodeID -> outputName -> value
-
-	// Execute nodes in order
-	for _, node := range execOrder {
-		nodeResult, err := e.executeNode(ctx, f, node, inputs, nodeOutputs)
-		result.NodeResults = append(result.NodeResults, *nodeResult)
-
+	// When re-running from a node, collect the results to reuse
+	var reuse map[string]*flow.NodeResult
+	if start != nil {
+		reuse, err = start.reusedResults(f)
 		if err != nil {
-			result.Error = fmt.Sprintf("node %s failed: %v", node.ID, err)
+			result.Error = fmt.Sprintf("cannot re-run from node %s: %v", start.nodeID, err)
 			result.EndTime = time.Now()
 			result.Duration = time.Since(startTime)
 			return result, err
 		}
+	}
 
-		// Store node outputs
-		nodeOutputs[node.ID] = nodeResult.Outputs
+	// Parse the partials once for every template rendered in the run
+	partials, err := f.ParsePartials(e.templateFuncs)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to load partials: %v", err)
+		result.EndTime = time.Now()
+		result.Duration = time.Since(startTime)
+		return result, err
 	}
 
-	// Collect flow outputs
+	// Execute nodes, running independent nodes concurrently
+	ctx = withPartials(ctx, f, partials)
+	ctx = withBudget(ctx, f.Config.Budget)
+	flowCtx, cancel := withTimeout(ctx, f.Config.Timeout)
+	defer cancel()
+
+	nodeResults, nodeOutputs, nodeErrors, failedNode, err := e.runNodes(flowCtx, f, execOrder, inputs, reuse)
+	result.NodeResults = nodeResults
+	result.Errors = nodeErrors
+	for _, nodeResult := range nodeResults {
+		if !nodeResult.Reused {
+			addMetrics(&result.Metrics, nodeResult.Metrics)
+		}
+	}
+	if err != nil {
+		err = timeoutError(ctx, flowCtx, "", f.Config.Timeout, err)
+		var timeoutErr *TimeoutError
+		var budgetErr *BudgetExceededError
+		if errors.As(err, &timeoutErr) && timeoutErr.NodeID == failedNode ||
+			errors.As(err, &budgetErr) && budgetErr.NodeID == failedNode {
+			result.Error = err.Error()
+		} else {
+			result.Error = fmt.Sprintf("node %s failed: %v", failedNode, err)
+		}
+		result.EndTime = time.Now()
+		result.Duration = time.Since(startTime)
+		return result, err
+	}
+
+	// Collect flow outputs. Outputs of skipped nodes are omitted unless they declare a default.
 	for _, node := range f.Nodes {
 		for _, output := range node.Outputs {
-			if output.To == "output" {
-				result.Outputs[output.Name] = nodeOutputs[node.ID][output.Name]
+			if output.To != "output" {
+				continue
+			}
+			if val, ok := nodeOutputs[node.ID][output.Name]; ok {
+				result.Outputs[output.Name] = val
 			}
 		}
 	}
 
+	// Nodes that failed under the "continue" policy leave a partial result
+	if len(nodeErrors) > 0 {
+		err := partialError(nodeErrors, len(f.Nodes))
+		result.Error = err.Error()
+		result.EndTime = time.Now()
+		result.Duration = time.Since(startTime)
+		return result, err
+	}
+
 	result.Success = true
 	result.EndTime = time.Now()
 	result.Duration = time.Since(startTime)
@@ -88,79 +238,67 @@ func (e *Executor) Execute(ctx context.Context, f *flow.Flow, inputs map[string]
 	return result, nil
 }
 
+// partialError summarises the nodes that failed in a run that continued past them
+func partialError(nodeErrors []flow.NodeError, total int) error {
+	ids := make([]string, len(nodeErrors))
+	for i, nodeErr := range nodeErrors {
+		ids[i] = nodeErr.NodeID
+	}
+	return fmt.Errorf("%d of %d nodes failed: %s", len(nodeErrors), total, strings.Join(ids, ", "))
+}
+
 func (e *Executor) executeNode(
 	ctx context.Context,
 	f *flow.Flow,
 	node *flow.Node,
-	flowInputs map[string]any,
-	nodeOutputs map[string]map[string]any,
+	inputData map[string]any,
 ) (*flow.NodeResult, error) {
 	startTime := time.Now()
 
 	result := &flow.NodeResult{
 		NodeID:    node.ID,
+		Status:    flow.NodeStatusFailed,
 		Success:   false,
 		Outputs:   make(map[string]any),
 		StartTime: startTime,
 	}
 
-	// Build input data for this node
-	inputData := make(map[string]any)
-	for _, input := range node.Inputs {
-		if input.From == "input" {
-			// Get from flow inputs
-			if val, ok := flowInputs[input.Name]; ok {
-				inputData[input.Name] = val
-			} else {
-				err := fmt.Errorf("flow input not provided: %s", input.Name)
-				result.Error = err.Error()
-				result.EndTime = time.Now()
-				result.Duration = time.Since(startTime)
-				return result, err
-			}
-		} else {
-			// Get from another node's output
-			parts := strings.SplitN(input.From, ".", 2)
-			if len(parts) != 2 {
-				err := fmt.Errorf("invalid input reference: %s", input.From)
-				result.Error = err.Error()
-				result.EndTime = time.Now()
-				result.Duration = time.Since(startTime)
-				return result, err
-			}
+	handler, ok := e.handlerFor(node.TypeName())
+	if !ok {
+		err := fmt.Errorf("no handler registered for node type: %s", node.TypeName())
+		result.Error = err.Error()
+		result.EndTime = time.Now()
+		result.Duration = time.Since(startTime)
+		return result, err
+	}
 
-			nodeID, outputName := parts[0], parts[1]
-			if outputs, ok := nodeOutputs[nodeID]; ok {
-				if val, ok := outputs[outputName]; ok {
-					inputData[input.Name] = val
-				} else {
-					err := fmt.Errorf("output not found: %s.%s", nodeID, outputName)
-					result.Error = err.Error()
-					result.EndTime = time.Now()
-					result.Duration = time.Since(startTime)
-					return result, err
-				}
-			} else {
-				err := fmt.Errorf("node outputs not found: %s", nodeID)
-				result.Error = err.Error()
-				result.EndTime = time.Now()
-				result.Duration = time.Since(startTime)
-				return result, err
-			}
-		}
+	nodeCtx, cancel := withTimeout(ctx, node.Timeout)
+	defer cancel()
+
+	e.notify(func(o Observer) { o.NodeStart(nodeCtx, node, inputData) })
+	defer e.notify(func(o Observer) { o.NodeEnd(ctx, node, result) })
+
+	if err := budgetFrom(ctx).check(node.ID); err != nil {
+		result.Error = err.Error()
+		result.EndTime = time.Now()
+		result.Duration = time.Since(startTime)
+		return result, err
 	}
 
-	// TODO: support other node types?
-	output, metrics, err := e.executeLLMNode(ctx, f, node, inputData)
-	if err != nil {
+	req := NodeRequest{
+		Flow:   f,
+		Node:   node,
+		Inputs: inputData,
+	}
+	if err := handler.Execute(nodeCtx, req, result); err != nil {
+		err = timeoutError(ctx, nodeCtx, node.ID, node.Timeout, err)
 		result.Error = err.Error()
 		result.EndTime = time.Now()
 		result.Duration = time.Since(startTime)
 		return result, err
 	}
-	result.Outputs = output
-	result.Metrics = *metrics
 
+	result.Status = flow.NodeStatusSucceeded
 	result.Success = true
 	result.EndTime = time.Now()
 	result.Duration = time.Since(startTime)
@@ -173,19 +311,14 @@ func (e *Executor) executeLLMNode(
 	f *flow.Flow,
 	node *flow.Node,
 	inputData map[string]any,
-) (map[string]any, *flow.NodeMetrics, error) {
-	// Render prompt template
-	tmpl, err := template.New(node.ID).Parse(node.Prompt)
+	result *flow.NodeResult,
+) error {
+	// Render the prompt, or the messages, templates
+	rendered, err := e.renderRequest(ctx, f, node, inputData)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to parse prompt template: %w", err)
-	}
-
-	var promptBuf bytes.Buffer
-	if err := tmpl.Execute(&promptBuf, inputData); err != nil {
-		return nil, nil, fmt.Errorf("failed to execute prompt template: %w", err)
+		return err
 	}
-
-	prompt := promptBuf.String()
+	e.notify(func(o Observer) { o.PromptRendered(ctx, node, rendered.Text()) })
 
 	// Get provider
 	providerName := node.Provider
@@ -193,12 +326,7 @@ func (e *Executor) executeLLMNode(
 		providerName = f.Config.DefaultProvider
 	}
 	if providerName == "" {
-		return nil, nil, fmt.Errorf("no provider specified for node and no default provider set")
-	}
-
-	provider, ok := e.registry.Get(providerName)
-	if !ok {
-		return nil, nil, fmt.Errorf("provider not found: %s", providerName)
+		return fmt.Errorf("no provider specified for node and no default provider set")
 	}
 
 	// Get model
@@ -207,37 +335,206 @@ func (e *Executor) executeLLMNode(
 		model = f.Config.DefaultModel
 	}
 	if model == "" {
-		return nil, nil, fmt.Errorf("no model specified for node and no default model set")
+		return fmt.Errorf("no model specified for node and no default model set")
 	}
 
-	// Call LLM
-	req := providers.CompletionRequest{
-		Prompt:   prompt,
-		Model:    model,
-		Settings: node.Settings,
+	responseSchema, err := f.LoadSchema(node)
+	if err != nil {
+		return err
 	}
 
-	resp, err := provider.Complete(ctx, req)
+	// Try the primary provider, then each fallback once the previous one has run out
+	// of retries
+	targets, err := e.llmTargets(node, providerName, model)
 	if err != nil {
-		return nil, nil, fmt.Errorf("LLM call failed: %w", err)
+		return err
 	}
 
-	// Build outputs
-	outputs := make(map[string]any)
-	if len(node.Outputs) > 0 {
-		// For now, assume first output gets the response content
-		// In the future, we could parse structured outputs
-		outputs[node.Outputs[0].Name] = resp.Content
+	var resp *providers.CompletionResponse
+	var answered llmTarget
+	for _, target := range targets {
+		req := rendered
+		req.Model = target.model
+		req.Settings = target.settings
+
+		resp, err = e.completeWithRetry(ctx, node, target.providerName, target.provider, req, retryPolicy(f, node), result)
+		if err == nil {
+			answered = target
+			result.Provider = target.providerName
+			result.Model = target.model
+			result.Cached = resp.Cached
+			break
+		}
+		if ctx.Err() != nil || isBudgetExceeded(err) {
+			break
+		}
+	}
+	if err != nil {
+		if isBudgetExceeded(err) {
+			return err
+		}
+		if len(targets) > 1 {
+			return fmt.Errorf("LLM call failed on primary and %d fallbacks, last error: %w", len(targets)-1, err)
+		}
+		return fmt.Errorf("LLM call failed: %w", err)
 	}
 
-	metrics := &flow.NodeMetrics{
+	result.RawOutput = resp.Content
+	result.Metrics = flow.NodeMetrics{
 		InputTokens:  resp.InputTokens,
 		OutputTokens: resp.OutputTokens,
 		InputCost:    resp.InputCost,
 		OutputCost:   resp.OutputCost,
 	}
 
-	return outputs, metrics, nil
+	// Build outputs, from the parsed response once it matches the schema if there is one
+	var outputs map[string]any
+	if responseSchema != nil {
+		var value any
+		resp, value, err = e.repairUntilValid(ctx, f, node, answered, rendered, resp, responseSchema, result)
+		if err != nil {
+			return err
+		}
+		result.RawOutput = resp.Content
+		outputs, err = parsedOutputs(node, value)
+	} else {
+		outputs, err = buildOutputs(node, resp.Content)
+	}
+	if err != nil {
+		return err
+	}
+	result.Outputs = outputs
+
+	return nil
+}
+
+// llmTarget is a provider and model an llm node can be sent to
+type llmTarget struct {
+	providerName string
+	provider     providers.Provider
+	model        string
+	settings     map[string]any
+}
+
+// llmTargets returns the node's primary provider and model followed by its fallbacks.
+// Fallback fields left empty take the primary's values.
+func (e *Executor) llmTargets(node *flow.Node, providerName, model string) ([]llmTarget, error) {
+	primary := flow.Fallback{Provider: providerName, Model: model, Settings: node.Settings}
+
+	targets := make([]llmTarget, 0, len(node.Fallbacks)+1)
+	for _, fallback := range append([]flow.Fallback{primary}, node.Fallbacks...) {
+		if fallback.Provider == "" {
+			fallback.Provider = providerName
+		}
+		if fallback.Model == "" {
+			fallback.Model = model
+		}
+		if fallback.Settings == nil {
+			fallback.Settings = node.Settings
+		}
+
+		provider, ok := e.registry.Get(fallback.Provider)
+		if !ok {
+			return nil, fmt.Errorf("provider not found: %s", fallback.Provider)
+		}
+
+		targets = append(targets, llmTarget{
+			providerName: fallback.Provider,
+			provider:     provider,
+			model:        fallback.Model,
+			settings:     fallback.Settings,
+		})
+	}
+
+	return targets, nil
+}
+
+// addMetrics adds the token counts and costs of m to total
+func addMetrics(total *flow.NodeMetrics, m flow.NodeMetrics) {
+	total.InputTokens += m.InputTokens
+	total.OutputTokens += m.OutputTokens
+	total.InputCost += m.InputCost
+	total.OutputCost += m.OutputCost
+}
+
+// renderPrompt renders the node's prompt, or the contents of its prompt file, as a Go
+// template over its input data
+func (e *Executor) renderPrompt(ctx context.Context, f *flow.Flow, node *flow.Node, inputData map[string]any) (string, error) {
+	text, err := f.LoadPrompt(node)
+	if err != nil {
+		return "", err
+	}
+	prompt, err := e.renderTemplate(ctx, f, node.ID, text, inputData)
+	if err != nil {
+		return "", fmt.Errorf("prompt template: %w", err)
+	}
+	return prompt, nil
+}
+
+// renderRequest renders the prompt of an llm node, or its messages if it has them,
+// into a request without a model or settings
+func (e *Executor) renderRequest(ctx context.Context, f *flow.Flow, node *flow.Node, inputData map[string]any) (providers.CompletionRequest, error) {
+	if len(node.Messages) == 0 {
+		prompt, err := e.renderPrompt(ctx, f, node, inputData)
+		return providers.CompletionRequest{Prompt: prompt}, err
+	}
+
+	messages := make([]providers.Message, len(node.Messages))
+	for i, message := range node.Messages {
+		content, err := e.renderTemplate(ctx, f, fmt.Sprintf("%s.messages[%d]", node.ID, i), message.Content, inputData)
+		if err != nil {
+			return providers.CompletionRequest{}, fmt.Errorf("messages[%d] template: %w", i, err)
+		}
+		messages[i] = providers.Message{Role: message.Role, Content: content}
+	}
+	return providers.CompletionRequest{Messages: messages}, nil
+}
+
+// renderTemplate renders text as a Go template over data, with the built-in template
+// functions, any added with WithTemplateFuncs and the flow's partials. The partials
+// are those parsed at the start of the run, and are only read here outside of a run.
+func (e *Executor) renderTemplate(ctx context.Context, f *flow.Flow, name, text string, data map[string]any) (string, error) {
+	var tmpl *template.Template
+	var err error
+	if partials := partialsFrom(ctx, f); partials != nil {
+		tmpl, err = flow.ParseWithPartials(partials, name, text)
+	} else {
+		tmpl, err = f.ParseTemplate(name, text, e.templateFuncs)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to execute template: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+// runPartials are the partials of a flow, parsed once per run
+type runPartials struct {
+	flow     *flow.Flow
+	partials *template.Template
+}
+
+type partialsKey struct{}
+
+// withPartials returns a context carrying the parsed partials of the run of f. A
+// subflow run replaces them with its own.
+func withPartials(ctx context.Context, f *flow.Flow, partials *template.Template) context.Context {
+	return context.WithValue(ctx, partialsKey{}, &runPartials{flow: f, partials: partials})
+}
+
+// partialsFrom returns the parsed partials of the run of f that ctx belongs to, or
+// nil outside of one
+func partialsFrom(ctx context.Context, f *flow.Flow) *template.Template {
+	p, _ := ctx.Value(partialsKey{}).(*runPartials)
+	if p == nil || p.flow != f {
+		return nil
+	}
+	return p.partials
 }
 
 func (e *Executor) topologicalSort(f *flow.Flow) ([]*flow.Node, error) {
@@ -255,25 +552,21 @@ func (e *Executor) topologicalSort(f *flow.Flow) ([]*flow.Node, error) {
 	}
 
 	// Build graph
+	graph := f.DependencyGraph()
 	for _, node := range f.Nodes {
-		for _, input := range node.Inputs {
-			if input.From != "input" {
-				parts := strings.SplitN(input.From, ".", 2)
-				if len(parts) == 2 {
-					sourceNode := parts[0]
-					// Add edge from sourceNode to current node
-					adjList[sourceNode] = append(adjList[sourceNode], node.ID)
-					inDegree[node.ID]++
-				}
-			}
+		for _, sourceNode := range graph[node.ID] {
+			// Add edge from sourceNode to current node
+			adjList[sourceNode] = append(adjList[sourceNode], node.ID)
+			inDegree[node.ID]++
 		}
 	}
 
-	// Kahn's algorithm for topological sort
+	// Kahn's algorithm for topological sort, seeded in declaration order so the
+	// resulting order is deterministic
 	queue := []string{}
-	for nodeID, degree := range inDegree {
-		if degree == 0 {
-			queue = append(queue, nodeID)
+	for _, node := range f.Nodes {
+		if inDegree[node.ID] == 0 {
+			queue = append(queue, node.ID)
 		}
 	}
 
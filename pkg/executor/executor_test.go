@@ -0,0 +1,75 @@
+package executor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/broderick/prompt-flow/pkg/flow"
+	"github.com/broderick/prompt-flow/pkg/providers"
+)
+
+// fakeProvider answers completions with a function, so tests can script replies,
+// delays and failures
+type fakeProvider struct {
+	name     string // defaults to "fake"
+	complete func(ctx context.Context, req providers.CompletionRequest) (*providers.CompletionResponse, error)
+}
+
+func (p *fakeProvider) Name() string {
+	if p.name == "" {
+		return "fake"
+	}
+	return p.name
+}
+
+func (p *fakeProvider) Complete(ctx context.Context, req providers.CompletionRequest) (*providers.CompletionResponse, error) {
+	return p.complete(ctx, req)
+}
+
+// echo answers every request with its prompt
+func echo(ctx context.Context, req providers.CompletionRequest) (*providers.CompletionResponse, error) {
+	return &providers.CompletionResponse{Content: req.Prompt, InputTokens: 10, OutputTokens: 5}, nil
+}
+
+// newTestExecutor returns an executor whose only provider is a fakeProvider answering
+// with complete
+func newTestExecutor(
+	complete func(ctx context.Context, req providers.CompletionRequest) (*providers.CompletionResponse, error),
+	opts ...Option,
+) *Executor {
+	registry := providers.NewRegistry()
+	registry.Register(&fakeProvider{complete: complete})
+	return New(registry, opts...)
+}
+
+// parseFlow parses a YAML flow definition, failing the test if it is malformed
+func parseFlow(t *testing.T, src string) *flow.Flow {
+	t.Helper()
+	f, err := flow.ParseBytes([]byte(src), "test.flow.yaml")
+	if err != nil {
+		t.Fatalf("parsing flow: %v", err)
+	}
+	return f
+}
+
+// nodeResult returns the result of the node with the given ID, failing the test if
+// there is none
+func nodeResult(t *testing.T, result *flow.ExecutionResult, nodeID string) flow.NodeResult {
+	t.Helper()
+	for _, r := range result.NodeResults {
+		if r.NodeID == nodeID {
+			return r
+		}
+	}
+	t.Fatalf("no result for node %s", nodeID)
+	return flow.NodeResult{}
+}
+
+// resultIDs returns the node IDs of a run's results, in order
+func resultIDs(result *flow.ExecutionResult) []string {
+	ids := make([]string, len(result.NodeResults))
+	for i, r := range result.NodeResults {
+		ids[i] = r.NodeID
+	}
+	return ids
+}
@@ -0,0 +1,127 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/broderick/prompt-flow/pkg/flow"
+)
+
+// NodeHandler implements a node type. Library users can add their own node types
+// by implementing this interface and registering it with RegisterNodeHandler.
+type NodeHandler interface {
+	// Execute runs the node. Implementations should write the node's outputs, and
+	// any metrics or raw output, to result. The executor fills in the node ID,
+	// success flag and timings.
+	Execute(ctx context.Context, req NodeRequest, result *flow.NodeResult) error
+}
+
+// NodeValidator can be implemented by a NodeHandler to check the fields of a node
+// that are specific to its node type. Validate is called by flow.Validate for every
+// node of the handler's type.
+type NodeValidator interface {
+	Validate(node *flow.Node) error
+}
+
+// NodeRequest holds everything a NodeHandler needs to execute a node
+type NodeRequest struct {
+	Flow   *flow.Flow     // The flow being executed
+	Node   *flow.Node     // The node to execute
+	Inputs map[string]any // Resolved node inputs, keyed by input name
+}
+
+var (
+	handlersMu sync.RWMutex
+	handlers   = make(map[string]NodeHandler)
+)
+
+// RegisterNodeHandler registers a handler for a custom node type. The type also
+// becomes known to flow.Validate, which calls the handler's Validate method if it
+// implements NodeValidator. Built-in node types cannot be replaced.
+func RegisterNodeHandler(nodeType string, handler NodeHandler) error {
+	if nodeType == "" {
+		return fmt.Errorf("node type name is required")
+	}
+	if handler == nil {
+		return fmt.Errorf("node handler for type %s is nil", nodeType)
+	}
+	if isBuiltinNodeType(nodeType) {
+		return fmt.Errorf("cannot replace built-in node type: %s", nodeType)
+	}
+
+	handlersMu.Lock()
+	handlers[nodeType] = handler
+	handlersMu.Unlock()
+
+	var validate flow.NodeTypeValidator
+	if validator, ok := handler.(NodeValidator); ok {
+		validate = validator.Validate
+	}
+	flow.RegisterNodeType(nodeType, validate)
+	return nil
+}
+
+// isBuiltinNodeType reports whether the node type is implemented by the executor itself
+func isBuiltinNodeType(nodeType string) bool {
+	switch nodeType {
+	case flow.NodeTypeLLM, flow.NodeTypeTemplate, flow.NodeTypeHTTP, flow.NodeTypeSwitch, flow.NodeTypeMap, flow.NodeTypeSubflow:
+		return true
+	}
+	return false
+}
+
+// handlerFor returns the handler for a node type, preferring built-in types
+func (e *Executor) handlerFor(nodeType string) (NodeHandler, bool) {
+	switch nodeType {
+	case flow.NodeTypeLLM:
+		return llmHandler{e: e}, true
+	case flow.NodeTypeTemplate:
+		return templateHandler{e: e}, true
+	case flow.NodeTypeHTTP:
+		return httpHandler{e: e}, true
+	case flow.NodeTypeSwitch:
+		return switchHandler{}, true
+	case flow.NodeTypeMap:
+		return mapHandler{e: e}, true
+	case flow.NodeTypeSubflow:
+		return subflowHandler{e: e}, true
+	}
+
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	handler, ok := handlers[nodeType]
+	return handler, ok
+}
+
+// llmHandler implements the built-in "llm" node type
+type llmHandler struct {
+	e *Executor
+}
+
+func (h llmHandler) Execute(ctx context.Context, req NodeRequest, result *flow.NodeResult) error {
+	return h.e.executeLLMNode(ctx, req.Flow, req.Node, req.Inputs, result)
+}
+
+// templateHandler implements the built-in "template" node type, which renders its
+// prompt without calling a model
+type templateHandler struct {
+	e *Executor
+}
+
+func (h templateHandler) Execute(ctx context.Context, req NodeRequest, result *flow.NodeResult) error {
+	text, err := h.e.renderPrompt(ctx, req.Flow, req.Node, req.Inputs)
+	if err != nil {
+		return err
+	}
+
+	// No model is called, so metrics stay at zero tokens and zero cost
+	result.RawOutput = text
+	outputs, err := buildOutputs(req.Node, text)
+	if err != nil {
+		return err
+	}
+	result.Outputs = outputs
+
+	return nil
+}
@@ -0,0 +1,199 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/broderick/prompt-flow/pkg/flow"
+)
+
+// maxErrorBodyLength limits how much of a response body is included in error messages
+const maxErrorBodyLength = 200
+
+// httpHandler implements the built-in "http" node type
+type httpHandler struct {
+	e *Executor
+}
+
+// httpResponse is the part of an HTTP response that http nodes care about
+type httpResponse struct {
+	status int
+	body   []byte
+}
+
+func (h httpHandler) Execute(ctx context.Context, req NodeRequest, result *flow.NodeResult) error {
+	cfg := req.Node.HTTP
+
+	// Render the request from the node inputs
+	method, err := h.e.renderTemplate(ctx, req.Flow, req.Node.ID+".method", cfg.Method, req.Inputs)
+	if err != nil {
+		return fmt.Errorf("method template: %w", err)
+	}
+	method = strings.ToUpper(strings.TrimSpace(method))
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	url, err := h.e.renderTemplate(ctx, req.Flow, req.Node.ID+".url", cfg.URL, req.Inputs)
+	if err != nil {
+		return fmt.Errorf("url template: %w", err)
+	}
+	url = strings.TrimSpace(url)
+
+	headers := make(map[string]string, len(cfg.Headers))
+	for name, value := range cfg.Headers {
+		rendered, err := h.e.renderTemplate(ctx, req.Flow, req.Node.ID+".headers."+name, value, req.Inputs)
+		if err != nil {
+			return fmt.Errorf("header %s template: %w", name, err)
+		}
+		headers[name] = rendered
+	}
+
+	body, err := h.e.renderTemplate(ctx, req.Flow, req.Node.ID+".body", cfg.Body, req.Inputs)
+	if err != nil {
+		return fmt.Errorf("body template: %w", err)
+	}
+
+	// Send the request, retrying network errors, 429s and 5xx responses
+	var resp *httpResponse
+	for attempt := 0; attempt <= cfg.Retries; attempt++ {
+		if attempt > 0 && cfg.RetryDelay > 0 {
+			select {
+			case <-time.After(cfg.RetryDelay.Std()):
+			case <-ctx.Done():
+				return fmt.Errorf("HTTP request cancelled: %w", ctx.Err())
+			}
+		}
+
+		resp, err = h.do(ctx, method, url, headers, body, cfg.Timeout.Std())
+		if ctx.Err() != nil {
+			break
+		}
+		if err == nil && resp.status != http.StatusTooManyRequests && resp.status < 500 {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("HTTP request failed: %w", err)
+	}
+
+	result.RawOutput = string(resp.body)
+	if resp.status < 200 || resp.status > 299 {
+		return fmt.Errorf("HTTP %s %s returned status %d: %s", method, url, resp.status, truncate(string(resp.body), maxErrorBodyLength))
+	}
+
+	outputs, err := extractHTTPOutputs(req.Node, resp)
+	if err != nil {
+		return err
+	}
+	result.Outputs = outputs
+
+	return nil
+}
+
+// do sends a single request and reads the whole response body
+func (h httpHandler) do(
+	ctx context.Context,
+	method, url string,
+	headers map[string]string,
+	body string,
+	timeout time.Duration,
+) (*httpResponse, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var bodyReader io.Reader
+	if body != "" {
+		bodyReader = strings.NewReader(body)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	for name, value := range headers {
+		httpReq.Header.Set(name, value)
+	}
+
+	httpResp, err := h.e.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	return &httpResponse{status: httpResp.StatusCode, body: respBody}, nil
+}
+
+// extractHTTPOutputs maps a response onto the node's outputs using its extract rules
+func extractHTTPOutputs(node *flow.Node, resp *httpResponse) (map[string]any, error) {
+	outputs := make(map[string]any)
+	if len(node.HTTP.Extract) == 0 {
+		if len(node.Outputs) > 0 {
+			outputs[node.Outputs[0].Name] = string(resp.body)
+		}
+		return outputs, nil
+	}
+
+	var parsed any
+	var parseErr error
+	parsedBody := false
+
+	var errs []error
+	for _, output := range node.Outputs {
+		source, ok := node.HTTP.Extract[output.Name]
+		if !ok {
+			continue
+		}
+
+		switch {
+		case source == "status":
+			outputs[output.Name] = resp.status
+		case source == "body":
+			outputs[output.Name] = string(resp.body)
+		default:
+			if !parsedBody {
+				parseErr = json.Unmarshal(resp.body, &parsed)
+				parsedBody = true
+			}
+			if parseErr != nil {
+				errs = append(errs, fmt.Errorf("output %s: response body is not JSON: %w", output.Name, parseErr))
+				continue
+			}
+			val, err := lookupPath(parsed, strings.TrimPrefix(source, "body."))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("output %s: %s: %w", output.Name, source, err))
+				continue
+			}
+			outputs[output.Name] = val
+		}
+	}
+
+	return outputs, errors.Join(errs...)
+}
+
+// truncate shortens s to at most n bytes, cut on a character boundary, marking where
+// it was cut
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,151 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// httpFlow calls the URL given as an input and extracts its status and a JSON field
+const httpFlow = `
+version: "1.0"
+name: http
+inputs:
+  - name: url
+nodes:
+  - id: fetch
+    type: http
+    inputs:
+      - {name: url, from: input}
+    http:
+      url: "{{.url}}"
+      retries: RETRIES
+      extract:
+        status: status
+        sku: body.order.items[0].sku
+    outputs:
+      - {name: status, to: output}
+      - {name: sku, to: output}
+`
+
+// scriptedServer answers requests with the given statuses in turn, repeating the last,
+// and counts the requests it gets
+type scriptedServer struct {
+	statuses []int
+	body     string
+
+	mu       sync.Mutex
+	requests int
+}
+
+func (s *scriptedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	status := s.statuses[min(s.requests, len(s.statuses)-1)]
+	s.requests++
+	s.mu.Unlock()
+
+	w.WriteHeader(status)
+	if status == http.StatusOK {
+		fmt.Fprint(w, `{"order": {"items": [{"sku": "A-1"}]}}`)
+		return
+	}
+	fmt.Fprint(w, s.body)
+}
+
+func TestHTTPNode(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		body         string // body of responses other than 200
+		retries      int
+		wantRequests int
+		wantOutputs  map[string]any
+		wantErr      string // with the server URL written as URL
+	}{
+		{
+			name:         "success",
+			statuses:     []int{200},
+			retries:      2,
+			wantRequests: 1,
+			wantOutputs:  map[string]any{"status": 200, "sku": "A-1"},
+		},
+		{
+			name:         "retries a 5xx",
+			statuses:     []int{503, 200},
+			retries:      1,
+			wantRequests: 2,
+			wantOutputs:  map[string]any{"status": 200, "sku": "A-1"},
+		},
+		{
+			name:         "retries a 429",
+			statuses:     []int{429, 429, 200},
+			retries:      2,
+			wantRequests: 3,
+			wantOutputs:  map[string]any{"status": 200, "sku": "A-1"},
+		},
+		{
+			name:         "fails once retries run out",
+			statuses:     []int{500},
+			body:         "internal error",
+			retries:      1,
+			wantRequests: 2,
+			wantErr:      "HTTP GET URL returned status 500: internal error",
+		},
+		{
+			name:         "does not retry a 4xx",
+			statuses:     []int{404, 200},
+			body:         "no such order",
+			retries:      2,
+			wantRequests: 1,
+			wantErr:      "HTTP GET URL returned status 404: no such order",
+		},
+		{
+			name:         "fails on a non-2xx status that is not an error",
+			statuses:     []int{304},
+			retries:      2,
+			wantRequests: 1,
+			wantErr:      "HTTP GET URL returned status 304",
+		},
+		{
+			name:         "cuts a long error body on a character boundary",
+			statuses:     []int{400},
+			body:         "x" + strings.Repeat("é", 150),
+			wantRequests: 1,
+			wantErr:      "HTTP GET URL returned status 400: x" + strings.Repeat("é", 99) + "...",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &scriptedServer{statuses: tt.statuses, body: tt.body}
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			f := parseFlow(t, strings.Replace(httpFlow, "RETRIES", fmt.Sprint(tt.retries), 1))
+			e := newTestExecutor(echo, WithHTTPClient(server.Client()))
+			result, err := e.Execute(context.Background(), f, map[string]any{"url": server.URL})
+
+			if handler.requests != tt.wantRequests {
+				t.Errorf("server got %d requests, want %d", handler.requests, tt.wantRequests)
+			}
+			if tt.wantErr != "" {
+				want := strings.ReplaceAll(tt.wantErr, "URL", server.URL)
+				if err == nil || !strings.Contains(err.Error(), want) {
+					t.Errorf("Execute error = %v, want %q", err, want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+			if !reflect.DeepEqual(result.Outputs, tt.wantOutputs) {
+				t.Errorf("outputs = %v, want %v", result.Outputs, tt.wantOutputs)
+			}
+		})
+	}
+}
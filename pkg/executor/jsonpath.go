@@ -0,0 +1,74 @@
+package executor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// lookupPath finds a value inside decoded JSON using a dotted path with optional
+// array indexes, e.g. "order.items[0].sku". A leading "$." is ignored.
+func lookupPath(value any, path string) (any, error) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return value, nil
+	}
+
+	current := value
+	for _, segment := range strings.Split(path, ".") {
+		key, indexes, err := splitSegment(segment)
+		if err != nil {
+			return nil, err
+		}
+
+		if key != "" {
+			obj, ok := current.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("cannot look up %q: not an object", key)
+			}
+			current, ok = obj[key]
+			if !ok {
+				return nil, fmt.Errorf("field not found: %s", key)
+			}
+		}
+
+		for _, index := range indexes {
+			list, ok := current.([]any)
+			if !ok {
+				return nil, fmt.Errorf("cannot index [%d]: not an array", index)
+			}
+			if index < 0 || index >= len(list) {
+				return nil, fmt.Errorf("index out of range: [%d] (length %d)", index, len(list))
+			}
+			current = list[index]
+		}
+	}
+
+	return current, nil
+}
+
+// splitSegment splits a path segment such as "items[0][1]" into its key and indexes
+func splitSegment(segment string) (string, []int, error) {
+	open := strings.Index(segment, "[")
+	if open < 0 {
+		return segment, nil, nil
+	}
+
+	key := segment[:open]
+	rest := segment[open:]
+	indexes := []int{}
+	for rest != "" {
+		closing := strings.Index(rest, "]")
+		if rest[0] != '[' || closing < 0 {
+			return "", nil, fmt.Errorf("invalid path segment: %s", segment)
+		}
+		index, err := strconv.Atoi(rest[1:closing])
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid index in path segment %s: %w", segment, err)
+		}
+		indexes = append(indexes, index)
+		rest = rest[closing+1:]
+	}
+
+	return key, indexes, nil
+}
@@ -0,0 +1,74 @@
+package executor
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLookupPath(t *testing.T) {
+	var doc any
+	err := json.Unmarshal([]byte(`{
+		"order": {
+			"id": "A-1",
+			"items": [{"sku": "x", "tags": ["new", "sale"]}, {"sku": "y"}],
+			"total": 12.5,
+			"note": null
+		},
+		"matrix": [[1, 2], [3, 4]],
+		"name": "shop"
+	}`), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path    string
+		want    any
+		wantErr string
+	}{
+		{path: "", want: doc},
+		{path: "$", want: doc},
+		{path: "name", want: "shop"},
+		{path: "$.name", want: "shop"},
+		{path: "order.id", want: "A-1"},
+		{path: "order.total", want: 12.5},
+		{path: "order.note", want: nil},
+		{path: "order.items[0].sku", want: "x"},
+		{path: "order.items[1].sku", want: "y"},
+		{path: "order.items[0].tags[1]", want: "sale"},
+		{path: "matrix[1][0]", want: 3.0},
+		{path: "order.items[0]", want: map[string]any{"sku": "x", "tags": []any{"new", "sale"}}},
+
+		{path: "missing", wantErr: "field not found: missing"},
+		{path: "order.items[0].price", wantErr: "field not found: price"},
+		{path: "order.items[2]", wantErr: "index out of range: [2] (length 2)"},
+		{path: "order.items[-1]", wantErr: "index out of range: [-1]"},
+		{path: "name.first", wantErr: `cannot look up "first": not an object`},
+		{path: "order.note.text", wantErr: `cannot look up "text": not an object`},
+		{path: "order.items.sku", wantErr: `cannot look up "sku": not an object`},
+		{path: "order[0]", wantErr: "cannot index [0]: not an array"},
+		{path: "order.items[x]", wantErr: "invalid index in path segment items[x]"},
+		{path: "order.items[0", wantErr: "invalid path segment: items[0"},
+		{path: "order.items[0]x", wantErr: "invalid path segment: items[0]x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := lookupPath(doc, tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("lookupPath(%q) error = %v, want it to contain %q", tt.path, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("lookupPath(%q) unexpected error: %v", tt.path, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lookupPath(%q) = %#v, want %#v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+
+	"github.com/broderick/prompt-flow/pkg/flow"
+)
+
+// mapHandler implements the built-in "map" node type, which runs a nested node once
+// per item of a list input and collects the results into a list output
+type mapHandler struct {
+	e *Executor
+}
+
+func (h mapHandler) Execute(ctx context.Context, req NodeRequest, result *flow.NodeResult) error {
+	cfg := req.Node.Map
+
+	itemsInput := cfg.Items
+	if itemsInput == "" {
+		itemsInput = req.Node.Inputs[0].Name
+	}
+	items, err := toList(req.Inputs[itemsInput])
+	if err != nil {
+		return fmt.Errorf("input %s: %w", itemsInput, err)
+	}
+
+	// The map node holds one of the flow's slots while its items run, so items are
+	// limited separately rather than sharing the flow's limit
+	limit := cfg.MaxConcurrency
+	if limit <= 0 {
+		limit = h.e.concurrencyLimit(req.Flow)
+	}
+	if limit <= 0 || limit > len(items) {
+		limit = len(items)
+	}
+
+	itemNode := cfg.ItemNode(req.Node)
+	itemResults := make([]*flow.NodeResult, len(items))
+
+	// The first item to fail cancels the others, so its error is the one reported
+	var failOnce sync.Once
+	var failErr error
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Run items with at most limit in flight, stopping early on the first failure
+	sem := make(chan struct{}, max(limit, 1))
+	var wg sync.WaitGroup
+	for i, item := range items {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		inputData := maps.Clone(req.Inputs)
+		inputData[cfg.ItemInputName()] = item
+		inputData[flow.MapIndexInput] = i
+
+		// Name the item's node after its position, so observers can tell items apart
+		node := itemNode
+		node.ID = fmt.Sprintf("%s[%d]", req.Node.ID, i)
+
+		wg.Go(func() {
+			defer func() { <-sem }()
+			itemResult, err := h.e.executeNode(ctx, req.Flow, &node, inputData)
+			itemResults[i] = itemResult
+			if err != nil {
+				failOnce.Do(func() { failErr = fmt.Errorf("item %d: %w", i, err) })
+				cancel()
+			}
+		})
+	}
+	wg.Wait()
+
+	// Collect item results in order
+	values := make([]any, 0, len(items))
+	for _, itemResult := range itemResults {
+		if itemResult == nil {
+			continue
+		}
+		result.Items = append(result.Items, *itemResult)
+		addMetrics(&result.Metrics, itemResult.Metrics)
+		result.Throttled += itemResult.Throttled
+		values = append(values, itemValue(&itemNode, itemResult.Outputs))
+	}
+
+	if failErr != nil {
+		return failErr
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("map cancelled: %w", ctx.Err())
+	}
+
+	result.Outputs[req.Node.Outputs[0].Name] = values
+	return nil
+}
+
+// itemValue returns the value collected for one item: the nested node's only output,
+// or a map of all of its outputs when it has several
+func itemValue(node *flow.Node, outputs map[string]any) any {
+	if len(node.Outputs) == 1 {
+		return outputs[node.Outputs[0].Name]
+	}
+	return outputs
+}
+
+// toList converts a list input to a slice. Strings, such as LLM responses, are parsed as a JSON array.
+func toList(value any) ([]any, error) {
+	switch v := value.(type) {
+	case []any:
+		return v, nil
+	case []string:
+		items := make([]any, len(v))
+		for i, s := range v {
+			items[i] = s
+		}
+		return items, nil
+	case string:
+		return parseJSONList(v)
+	case nil:
+		return nil, fmt.Errorf("list value is not set")
+	}
+	return nil, fmt.Errorf("value of type %T is not a list", value)
+}
@@ -0,0 +1,220 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/broderick/prompt-flow/pkg/flow"
+	"github.com/broderick/prompt-flow/pkg/providers"
+)
+
+const mapFlow = `
+version: "1.0"
+name: mapping
+config:
+  default_provider: fake
+  default_model: m
+inputs:
+  - {name: tickets, type: list}
+nodes:
+  - id: summarize
+    type: map
+    inputs:
+      - {name: tickets, from: input}
+    map:
+      max_concurrency: 2
+      node:
+        prompt: "{{.index}}:{{.item}}"
+        outputs: [{name: summary}]
+    outputs:
+      - {name: summaries, to: output}
+`
+
+// startRecorder records the IDs of the nodes started
+type startRecorder struct {
+	NopObserver
+	mu  sync.Mutex
+	ids []string
+}
+
+func (r *startRecorder) NodeStart(ctx context.Context, node *flow.Node, inputs map[string]any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ids = append(r.ids, node.ID)
+}
+
+func TestMapNode(t *testing.T) {
+	// Later items finish first
+	delays := map[string]time.Duration{
+		"0:a": 30 * time.Millisecond,
+		"1:b": 20 * time.Millisecond,
+		"2:c": 10 * time.Millisecond,
+		"3:d": 0,
+	}
+
+	var mu sync.Mutex
+	running, highWater := 0, 0
+	observer := &startRecorder{}
+	e := newTestExecutor(func(ctx context.Context, req providers.CompletionRequest) (*providers.CompletionResponse, error) {
+		mu.Lock()
+		running++
+		highWater = max(highWater, running)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+
+		time.Sleep(delays[req.Prompt])
+		return &providers.CompletionResponse{Content: req.Prompt, InputTokens: 10, OutputTokens: 5, InputCost: 0.01, OutputCost: 0.02}, nil
+	}, WithObserver(observer))
+
+	result, err := e.Execute(context.Background(), parseFlow(t, mapFlow), map[string]any{"tickets": []any{"a", "b", "c", "d"}})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	if want := []any{"0:a", "1:b", "2:c", "3:d"}; !reflect.DeepEqual(result.Outputs["summaries"], want) {
+		t.Errorf("output summaries = %v, want %v", result.Outputs["summaries"], want)
+	}
+	if highWater != 2 {
+		t.Errorf("at most %d items ran at once, want the map's max_concurrency 2", highWater)
+	}
+
+	mapResult := nodeResult(t, result, "summarize")
+	var ids []string
+	for i, item := range mapResult.Items {
+		ids = append(ids, item.NodeID)
+		if want := []string{"0:a", "1:b", "2:c", "3:d"}[i]; item.Outputs["summary"] != want {
+			t.Errorf("item %d output = %v, want %q", i, item.Outputs["summary"], want)
+		}
+	}
+	wantIDs := []string{"summarize[0]", "summarize[1]", "summarize[2]", "summarize[3]"}
+	if !reflect.DeepEqual(ids, wantIDs) {
+		t.Errorf("item results %v, want %v", ids, wantIDs)
+	}
+
+	// Observers see each item under its own ID
+	observer.mu.Lock()
+	started := observer.ids
+	observer.mu.Unlock()
+	if len(started) != 5 || started[0] != "summarize" {
+		t.Fatalf("nodes started %v, want the map node and then its items", started)
+	}
+	items := append([]string(nil), started[1:]...)
+	if !sameStrings(items, wantIDs) {
+		t.Errorf("items started as %v, want %v", items, wantIDs)
+	}
+
+	want := flow.NodeMetrics{InputTokens: 40, OutputTokens: 20, InputCost: 0.04, OutputCost: 0.08}
+	if !metricsEqual(mapResult.Metrics, want) {
+		t.Errorf("map metrics = %+v, want %+v", mapResult.Metrics, want)
+	}
+	if !metricsEqual(result.Metrics, want) {
+		t.Errorf("run metrics = %+v, want %+v", result.Metrics, want)
+	}
+}
+
+func TestMapNodeThrottled(t *testing.T) {
+	registry := providers.NewRegistry()
+	registry.Register(&fakeProvider{complete: func(ctx context.Context, req providers.CompletionRequest) (*providers.CompletionResponse, error) {
+		time.Sleep(10 * time.Millisecond)
+		return echo(ctx, req)
+	}})
+	registry.WithRateLimit("fake", providers.RateLimit{MaxConcurrent: 1})
+
+	result, err := New(registry).Execute(context.Background(), parseFlow(t, mapFlow), map[string]any{"tickets": []any{"a", "b", "c"}})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	mapResult := nodeResult(t, result, "summarize")
+	var total time.Duration
+	for _, item := range mapResult.Items {
+		total += item.Throttled
+	}
+	if total == 0 {
+		t.Fatalf("no item waited for the rate limit")
+	}
+	if mapResult.Throttled != total {
+		t.Errorf("map throttled = %v, want the items' total %v", mapResult.Throttled, total)
+	}
+}
+
+func TestMapNodeItemFailureCancelsOthers(t *testing.T) {
+	var mu sync.Mutex
+	var started []string
+	var slowErr error
+	slowStarted := make(chan struct{})
+	e := newTestExecutor(func(ctx context.Context, req providers.CompletionRequest) (*providers.CompletionResponse, error) {
+		mu.Lock()
+		started = append(started, req.Prompt)
+		mu.Unlock()
+
+		switch req.Prompt {
+		case "0:slow":
+			close(slowStarted)
+			<-ctx.Done()
+			mu.Lock()
+			slowErr = ctx.Err()
+			mu.Unlock()
+			return nil, ctx.Err()
+		case "1:bad":
+			<-slowStarted
+			return nil, errors.New("boom")
+		}
+		return echo(ctx, req)
+	})
+
+	result, err := e.Execute(context.Background(), parseFlow(t, mapFlow), map[string]any{"tickets": []any{"slow", "bad", "c", "d"}})
+	if err == nil || !strings.Contains(err.Error(), "item 1: ") || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("Execute error = %v, want the failure of item 1", err)
+	}
+
+	if !errors.Is(slowErr, context.Canceled) {
+		t.Errorf("running item saw ctx error %v, want context.Canceled", slowErr)
+	}
+	if len(started) != 2 {
+		t.Errorf("items started %v, want only the first two", started)
+	}
+	if items := nodeResult(t, result, "summarize").Items; len(items) != 2 {
+		t.Errorf("map has %d item results, want 2", len(items))
+	}
+}
+
+// sameStrings reports whether a and b hold the same strings in any order
+func sameStrings(a, b []string) bool {
+	counts := make(map[string]int)
+	for _, s := range a {
+		counts[s]++
+	}
+	for _, s := range b {
+		counts[s]--
+	}
+	for _, n := range counts {
+		if n != 0 {
+			return false
+		}
+	}
+	return len(a) == len(b)
+}
+
+// metricsEqual compares metrics, allowing for rounding in the summed costs
+func metricsEqual(a, b flow.NodeMetrics) bool {
+	const epsilon = 1e-9
+	return a.InputTokens == b.InputTokens && a.OutputTokens == b.OutputTokens &&
+		abs(a.InputCost-b.InputCost) < epsilon && abs(a.OutputCost-b.OutputCost) < epsilon
+}
+
+func abs(x float64) float64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,66 @@
+package executor
+
+import (
+	"context"
+	"time"
+
+	"github.com/broderick/prompt-flow/pkg/flow"
+	"github.com/broderick/prompt-flow/pkg/providers"
+)
+
+// Observer receives events as the executor runs a flow, e.g. for logging, progress
+// output or tracing. Nodes run concurrently, so callbacks may be made from several
+// goroutines at once and implementations must be safe for concurrent use. Callbacks
+// run on the executor's goroutines and should return quickly.
+//
+// Embed NopObserver to implement only the callbacks you need.
+type Observer interface {
+	// FlowStart is called before a flow is validated and run. Subflow nodes start
+	// flows of their own.
+	FlowStart(ctx context.Context, f *flow.Flow, inputs map[string]any)
+	// FlowEnd is called once a flow has finished, whether or not it succeeded
+	FlowEnd(ctx context.Context, f *flow.Flow, result *flow.ExecutionResult)
+	// NodeStart is called when a node starts running, including each item of a map node
+	NodeStart(ctx context.Context, node *flow.Node, inputs map[string]any)
+	// NodeEnd is called when a node has finished, failed or been skipped
+	NodeEnd(ctx context.Context, node *flow.Node, result *flow.NodeResult)
+	// PromptRendered is called with the prompt of an llm node before it is sent. For
+	// nodes with messages, prompt holds them as "role: content" paragraphs.
+	PromptRendered(ctx context.Context, node *flow.Node, prompt string)
+	// ProviderRequest is called before each call to a provider
+	ProviderRequest(ctx context.Context, node *flow.Node, provider string, req providers.CompletionRequest)
+	// ProviderResponse is called after each call to a provider. Exactly one of resp and
+	// err is non-nil.
+	ProviderResponse(ctx context.Context, node *flow.Node, provider string, resp *providers.CompletionResponse, err error)
+	// Retry is called when a failed provider call will be retried after delay
+	Retry(ctx context.Context, node *flow.Node, failed flow.Attempt, delay time.Duration)
+}
+
+// NopObserver implements Observer with callbacks that do nothing
+type NopObserver struct{}
+
+func (NopObserver) FlowStart(context.Context, *flow.Flow, map[string]any)          {}
+func (NopObserver) FlowEnd(context.Context, *flow.Flow, *flow.ExecutionResult)     {}
+func (NopObserver) NodeStart(context.Context, *flow.Node, map[string]any)          {}
+func (NopObserver) NodeEnd(context.Context, *flow.Node, *flow.NodeResult)          {}
+func (NopObserver) PromptRendered(context.Context, *flow.Node, string)             {}
+func (NopObserver) Retry(context.Context, *flow.Node, flow.Attempt, time.Duration) {}
+func (NopObserver) ProviderRequest(context.Context, *flow.Node, string, providers.CompletionRequest) {
+}
+func (NopObserver) ProviderResponse(context.Context, *flow.Node, string, *providers.CompletionResponse, error) {
+}
+
+// WithObserver registers an observer for the executor's events. It can be given
+// several times; observers are called in the order they were registered.
+func WithObserver(observer Observer) Option {
+	return func(e *Executor) {
+		e.observers = append(e.observers, observer)
+	}
+}
+
+// notify calls fn for every registered observer
+func (e *Executor) notify(fn func(Observer)) {
+	for _, observer := range e.observers {
+		fn(observer)
+	}
+}
@@ -0,0 +1,108 @@
+package executor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/broderick/prompt-flow/pkg/flow"
+)
+
+// fencePattern matches a Markdown code fence, with an optional language tag, and captures its body
+var fencePattern = regexp.MustCompile("(?s)```[a-zA-Z]*\\s*\\n(.*?)```")
+
+// buildOutputs maps a raw response onto the node's declared outputs according to its output mode
+func buildOutputs(node *flow.Node, content string) (map[string]any, error) {
+	outputs := make(map[string]any)
+	if len(node.Outputs) == 0 {
+		return outputs, nil
+	}
+
+	if node.OutputMode != flow.OutputModeJSON {
+		// The whole response goes to the first output
+		outputs[node.Outputs[0].Name] = content
+		return outputs, nil
+	}
+
+	fields, err := parseJSONObject(content)
+	if err != nil {
+		return outputs, err
+	}
+
+	return fieldOutputs(node, fields)
+}
+
+// parsedOutputs maps an already parsed JSON response onto the node's declared outputs.
+// In text mode the whole value goes to the first output.
+func parsedOutputs(node *flow.Node, value any) (map[string]any, error) {
+	outputs := make(map[string]any)
+	if len(node.Outputs) == 0 {
+		return outputs, nil
+	}
+
+	if node.OutputMode != flow.OutputModeJSON {
+		outputs[node.Outputs[0].Name] = value
+		return outputs, nil
+	}
+
+	fields, ok := value.(map[string]any)
+	if !ok {
+		return outputs, fmt.Errorf("JSON response is not an object")
+	}
+
+	return fieldOutputs(node, fields)
+}
+
+// fieldOutputs fills each of the node's outputs from the field of the same name
+func fieldOutputs(node *flow.Node, fields map[string]any) (map[string]any, error) {
+	outputs := make(map[string]any)
+	var errs []error
+	for _, output := range node.Outputs {
+		val, ok := fields[output.Name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("output %s: field %q not found in JSON response", output.Name, output.Name))
+			continue
+		}
+		outputs[output.Name] = val
+	}
+
+	return outputs, errors.Join(errs...)
+}
+
+// parseJSONObject parses a response as a JSON object. If the response is not bare
+// JSON the first fenced code block (e.g. ```json ... ```) is tried instead.
+func parseJSONObject(content string) (map[string]any, error) {
+	var fields map[string]any
+	if err := parseJSON(content, &fields); err != nil {
+		return nil, fmt.Errorf("failed to parse response as a JSON object: %w", err)
+	}
+	return fields, nil
+}
+
+// parseJSONList parses a response as a JSON array, accepting a fenced code block like parseJSONObject
+func parseJSONList(content string) ([]any, error) {
+	var items []any
+	if err := parseJSON(content, &items); err != nil {
+		return nil, fmt.Errorf("failed to parse value as a JSON array: %w", err)
+	}
+	return items, nil
+}
+
+// parseJSON unmarshals content into v, falling back to the first fenced code block
+func parseJSON(content string, v any) error {
+	text := strings.TrimSpace(content)
+	err := json.Unmarshal([]byte(text), v)
+	if err == nil {
+		return nil
+	}
+
+	if m := fencePattern.FindStringSubmatch(text); m != nil {
+		if fenceErr := json.Unmarshal([]byte(strings.TrimSpace(m[1])), v); fenceErr == nil {
+			return nil
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,65 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/broderick/prompt-flow/pkg/providers"
+)
+
+// partialsFlow renders the tone partial before and after an llm call
+const partialsFlow = `
+version: "1.0"
+name: partials
+partials: partials
+config:
+  default_provider: fake
+  default_model: m
+nodes:
+  - {id: before, type: template, inputs: [], prompt: '{{template "tone"}}', outputs: [{name: text}]}
+  - {id: ask, inputs: [{name: text, from: before.text}], prompt: "{{.text}}", outputs: [{name: text}]}
+  - {id: after, type: template, inputs: [{name: text, from: ask.text}], prompt: '{{template "tone"}}', outputs: [{name: text}]}
+`
+
+func TestPartialsAreReadOncePerRun(t *testing.T) {
+	dir := t.TempDir()
+	partial := filepath.Join(dir, "partials", "tone.tmpl")
+	if err := os.Mkdir(filepath.Dir(partial), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writePartial := func(text string) {
+		t.Helper()
+		if err := os.WriteFile(partial, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writePartial("friendly")
+
+	// The llm call edits the partial in the middle of the run
+	e := newTestExecutor(func(ctx context.Context, req providers.CompletionRequest) (*providers.CompletionResponse, error) {
+		writePartial("formal")
+		return echo(ctx, req)
+	})
+
+	f := parseFlow(t, partialsFlow)
+	f.FilePath = filepath.Join(dir, "test.flow.yaml")
+
+	result, err := e.Execute(context.Background(), f, nil)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if got := nodeResult(t, result, "after").Outputs["text"]; got != "friendly" {
+		t.Errorf("partial rendered after the edit = %v, want the partial read at the start of the run", got)
+	}
+
+	// The next run reads the edited partial
+	result, err = e.Execute(context.Background(), f, nil)
+	if err != nil {
+		t.Fatalf("second Execute: %v", err)
+	}
+	if got := nodeResult(t, result, "before").Outputs["text"]; got != "formal" {
+		t.Errorf("partial rendered in the next run = %v, want the edited partial", got)
+	}
+}
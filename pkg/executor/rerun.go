@@ -0,0 +1,53 @@
+package executor
+
+import (
+	"fmt"
+
+	"github.com/broderick/prompt-flow/pkg/flow"
+)
+
+// runStart identifies where a re-run begins: the previous run and the first node to
+// execute again
+type runStart struct {
+	previous *flow.ExecutionResult
+	nodeID   string
+}
+
+// reusedResults returns the previous results of every node that is not re-run, that
+// is every node other than the start node and its descendants. Each of them must have
+// a result in the previous run, and nodes the re-run reads from must not have failed.
+func (s *runStart) reusedResults(f *flow.Flow) (map[string]*flow.NodeResult, error) {
+	graph := f.DependencyGraph()
+	if _, ok := graph[s.nodeID]; !ok {
+		return nil, fmt.Errorf("node not found: %s", s.nodeID)
+	}
+
+	recorded := make(map[string]*flow.NodeResult, len(s.previous.NodeResults))
+	for i := range s.previous.NodeResults {
+		recorded[s.previous.NodeResults[i].NodeID] = &s.previous.NodeResults[i]
+	}
+
+	rerun := flow.Descendants(graph, s.nodeID)
+
+	reuse := make(map[string]*flow.NodeResult)
+	for _, node := range f.Nodes {
+		if rerun[node.ID] {
+			continue
+		}
+		result, ok := recorded[node.ID]
+		if !ok {
+			return nil, fmt.Errorf("previous run has no result for node %s", node.ID)
+		}
+		reuse[node.ID] = result
+	}
+
+	for id := range rerun {
+		for _, upstream := range graph[id] {
+			if result, ok := reuse[upstream]; ok && result.Status == flow.NodeStatusFailed {
+				return nil, fmt.Errorf("node %s failed in the previous run; re-run from it instead", upstream)
+			}
+		}
+	}
+
+	return reuse, nil
+}
@@ -0,0 +1,119 @@
+package executor
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/broderick/prompt-flow/pkg/flow"
+	"github.com/broderick/prompt-flow/pkg/providers"
+)
+
+const rerunFlow = `
+version: "1.0"
+name: rerun
+config:
+  default_provider: fake
+  default_model: m
+inputs:
+  - name: topic
+nodes:
+  - {id: outline, inputs: [{name: topic, from: input}], prompt: "outline {{.topic}}", outputs: [{name: text}]}
+  - {id: title, inputs: [{name: outline, from: outline.text}], prompt: "title for {{.outline}}", outputs: [{name: text, to: output}]}
+  - {id: draft, inputs: [{name: outline, from: outline.text}, {name: topic, from: input}], prompt: "draft {{.topic}} from {{.outline}}", outputs: [{name: text}]}
+  - {id: final, type: template, inputs: [{name: draft, from: draft.text}], prompt: "final {{.draft}}", outputs: [{name: text, to: output}]}
+`
+
+// promptRecorder answers like echo and records the prompts it was sent
+type promptRecorder struct {
+	mu      sync.Mutex
+	prompts []string
+}
+
+func (r *promptRecorder) complete(ctx context.Context, req providers.CompletionRequest) (*providers.CompletionResponse, error) {
+	r.mu.Lock()
+	r.prompts = append(r.prompts, req.Prompt)
+	r.mu.Unlock()
+	return echo(ctx, req)
+}
+
+func TestExecuteFrom(t *testing.T) {
+	f := parseFlow(t, rerunFlow)
+	recorder := &promptRecorder{}
+	e := newTestExecutor(recorder.complete)
+
+	previous, err := e.Execute(context.Background(), f, map[string]any{"topic": "go"})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	// Re-run the draft with a new topic, keeping the outline and title
+	recorder.prompts = nil
+	result, err := e.ExecuteFrom(context.Background(), f, map[string]any{"topic": "rust"}, previous, "draft")
+	if err != nil {
+		t.Fatalf("ExecuteFrom: %v", err)
+	}
+
+	if want := []string{"draft rust from outline go"}; !slices.Equal(recorder.prompts, want) {
+		t.Errorf("provider was sent %q, want only the re-run node's %q", recorder.prompts, want)
+	}
+
+	wantReused := map[string]bool{"outline": true, "title": true, "draft": false, "final": false}
+	for id, want := range wantReused {
+		if r := nodeResult(t, result, id); r.Reused != want {
+			t.Errorf("node %s reused = %v, want %v", id, r.Reused, want)
+		}
+	}
+
+	if want := "final draft rust from outline go"; result.Outputs["text"] != want {
+		t.Errorf("output text = %v, want %q", result.Outputs["text"], want)
+	}
+	if result.Inputs["topic"] != "rust" {
+		t.Errorf("run inputs = %v, want the given topic to override the previous one", result.Inputs)
+	}
+
+	// Only the draft's call counts towards the totals
+	if want := (flow.NodeMetrics{InputTokens: 10, OutputTokens: 5}); result.Metrics != want {
+		t.Errorf("metrics = %+v, want only the re-run call's %+v", result.Metrics, want)
+	}
+}
+
+func TestExecuteFromErrors(t *testing.T) {
+	f := parseFlow(t, rerunFlow)
+	e := newTestExecutor(echo)
+	previous, err := e.Execute(context.Background(), f, map[string]any{"topic": "go"})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	failedOutline := *previous
+	failedOutline.NodeResults = append([]flow.NodeResult(nil), previous.NodeResults...)
+	failedOutline.NodeResults[0].Status = flow.NodeStatusFailed
+
+	missingTitle := *previous
+	missingTitle.NodeResults = []flow.NodeResult{previous.NodeResults[0]}
+
+	tests := []struct {
+		name     string
+		previous *flow.ExecutionResult
+		startAt  string
+		wantErr  string
+	}{
+		{name: "no previous run", startAt: "draft", wantErr: "requires the previous run's result"},
+		{name: "no start node", previous: previous, wantErr: "requires the ID of the node to start at"},
+		{name: "unknown start node", previous: previous, startAt: "missing", wantErr: "node not found: missing"},
+		{name: "reused node missing", previous: &missingTitle, startAt: "draft", wantErr: "previous run has no result for node title"},
+		{name: "reads from a failed node", previous: &failedOutline, startAt: "draft", wantErr: "node outline failed in the previous run"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := e.ExecuteFrom(context.Background(), f, nil, tt.previous, tt.startAt)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ExecuteFrom error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
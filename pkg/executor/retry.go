@@ -0,0 +1,150 @@
+package executor
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/broderick/prompt-flow/pkg/flow"
+	"github.com/broderick/prompt-flow/pkg/providers"
+)
+
+// Retry defaults used when a policy leaves a field unset
+const (
+	defaultBackoff    = time.Second
+	defaultMaxBackoff = 30 * time.Second
+	defaultMultiplier = 2.0
+)
+
+// defaultRetryOn lists the error classes retried when a policy does not set retry_on
+var defaultRetryOn = []providers.ErrorClass{
+	providers.ErrorClassRateLimit,
+	providers.ErrorClassServerError,
+	providers.ErrorClassTimeout,
+	providers.ErrorClassNetwork,
+}
+
+// retryPolicy returns the policy for a node's provider calls: the node's own policy if
+// set, otherwise the flow default. A nil policy means the call is made once.
+func retryPolicy(f *flow.Flow, node *flow.Node) *flow.RetryPolicy {
+	if node.Retry != nil {
+		return node.Retry
+	}
+	return f.Config.Retry
+}
+
+// completeWithRetry calls the provider, retrying failures allowed by the policy. Every
+// attempt is appended to result.Attempts. The last error is returned if all attempts fail.
+// Retrying stops as soon as ctx is done, so a node timeout bounds all of its attempts.
+// Attempts the provider sends, rather than answering from a cache or cassette, are
+// checked against and counted towards the run's budget, and the time they spend
+// waiting for the provider's rate limit is added to result.Throttled.
+func (e *Executor) completeWithRetry(
+	ctx context.Context,
+	node *flow.Node,
+	providerName string,
+	provider providers.Provider,
+	req providers.CompletionRequest,
+	policy *flow.RetryPolicy,
+	result *flow.NodeResult,
+) (*providers.CompletionResponse, error) {
+	maxAttempts := 1
+	var attemptTimeout flow.Duration
+	if policy != nil {
+		maxAttempts = max(policy.MaxAttempts, 1)
+		attemptTimeout = policy.AttemptTimeout
+	}
+
+	budget := budgetFrom(ctx)
+	ctx = providers.WithCallHooks(ctx, providers.CallHooks{
+		Before: func(req providers.CompletionRequest) error {
+			return budget.checkCall(node.ID, providerName, req)
+		},
+		Throttled: func(wait time.Duration) { result.Throttled += wait },
+		After: func(resp *providers.CompletionResponse) {
+			budget.add(flow.NodeMetrics{
+				InputTokens:  resp.InputTokens,
+				OutputTokens: resp.OutputTokens,
+				InputCost:    resp.InputCost,
+				OutputCost:   resp.OutputCost,
+			})
+		},
+	})
+
+	for attempt := 1; ; attempt++ {
+		startTime := time.Now()
+		attemptCtx, cancel := withTimeout(ctx, attemptTimeout)
+		e.notify(func(o Observer) { o.ProviderRequest(attemptCtx, node, providerName, req) })
+		resp, err := provider.Complete(attemptCtx, req)
+		e.notify(func(o Observer) { o.ProviderResponse(attemptCtx, node, providerName, resp, err) })
+		cancel()
+		if isBudgetExceeded(err) {
+			// The call was refused before it was sent
+			return nil, err
+		}
+
+		record := flow.Attempt{
+			Number:    attempt,
+			Provider:  providerName,
+			Model:     req.Model,
+			StartTime: startTime,
+			Latency:   time.Since(startTime),
+		}
+		if err != nil {
+			record.Error = err.Error()
+			record.ErrorClass = providers.ClassifyError(err)
+		}
+		result.Attempts = append(result.Attempts, record)
+
+		if err == nil {
+			return resp, nil
+		}
+		if attempt >= maxAttempts || !shouldRetry(policy, record.ErrorClass) || ctx.Err() != nil {
+			return nil, err
+		}
+
+		delay := backoffDelay(policy, attempt)
+		e.notify(func(o Observer) { o.Retry(ctx, node, record, delay) })
+
+		select {
+		case <-e.after(delay):
+		case <-ctx.Done():
+			return nil, err
+		}
+	}
+}
+
+// shouldRetry reports whether the policy retries errors of the given class
+func shouldRetry(policy *flow.RetryPolicy, class providers.ErrorClass) bool {
+	retryOn := policy.RetryOn
+	if len(retryOn) == 0 {
+		retryOn = defaultRetryOn
+	}
+	return slices.Contains(retryOn, class)
+}
+
+// backoffDelay returns how long to wait after the given failed attempt
+func backoffDelay(policy *flow.RetryPolicy, attempt int) time.Duration {
+	backoff := time.Duration(policy.Backoff)
+	if backoff == 0 {
+		backoff = defaultBackoff
+	}
+	maxBackoff := time.Duration(policy.MaxBackoff)
+	if maxBackoff == 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+	multiplier := policy.Multiplier
+	if multiplier == 0 {
+		multiplier = defaultMultiplier
+	}
+
+	delay := float64(backoff) * math.Pow(multiplier, float64(attempt-1))
+	delay = math.Min(delay, float64(maxBackoff))
+	if policy.Jitter > 0 {
+		delay += delay * policy.Jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(delay)
+}
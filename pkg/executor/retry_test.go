@@ -0,0 +1,200 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/broderick/prompt-flow/pkg/flow"
+	"github.com/broderick/prompt-flow/pkg/providers"
+)
+
+const retryFlow = `
+version: "1.0"
+name: retries
+config:
+  default_provider: fake
+  default_model: m
+nodes:
+  - {id: ask, inputs: [], prompt: hello, outputs: [{name: text, to: output}]}
+`
+
+// statusError is a provider error carrying an HTTP status code
+type statusError int
+
+func (e statusError) Error() string   { return fmt.Sprintf("status %d", int(e)) }
+func (e statusError) StatusCode() int { return int(e) }
+
+// failing returns a completion function that fails with errs in turn and then
+// answers every later call
+func failing(errs ...error) func(context.Context, providers.CompletionRequest) (*providers.CompletionResponse, error) {
+	calls := 0
+	return func(ctx context.Context, req providers.CompletionRequest) (*providers.CompletionResponse, error) {
+		calls++
+		if calls <= len(errs) {
+			return nil, errs[calls-1]
+		}
+		return echo(ctx, req)
+	}
+}
+
+// recordDelays makes e's retries return at once, recording the delays they would
+// have waited
+func recordDelays(e *Executor) *[]time.Duration {
+	delays := []time.Duration{}
+	e.after = func(d time.Duration) <-chan time.Time {
+		delays = append(delays, d)
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	}
+	return &delays
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      *flow.RetryPolicy
+		errs        []error
+		wantErr     bool
+		wantClasses []providers.ErrorClass // of each attempt, "" when it succeeded
+		wantDelays  []time.Duration
+	}{
+		{
+			name:        "no policy makes one attempt",
+			errs:        []error{statusError(500)},
+			wantErr:     true,
+			wantClasses: []providers.ErrorClass{providers.ErrorClassServerError},
+			wantDelays:  []time.Duration{},
+		},
+		{
+			name:        "retries until success",
+			policy:      &flow.RetryPolicy{MaxAttempts: 3},
+			errs:        []error{statusError(500), statusError(503)},
+			wantClasses: []providers.ErrorClass{providers.ErrorClassServerError, providers.ErrorClassServerError, ""},
+			wantDelays:  []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			name:        "stops after max attempts",
+			policy:      &flow.RetryPolicy{MaxAttempts: 2},
+			errs:        []error{statusError(429), statusError(429), statusError(429)},
+			wantErr:     true,
+			wantClasses: []providers.ErrorClass{providers.ErrorClassRateLimit, providers.ErrorClassRateLimit},
+			wantDelays:  []time.Duration{time.Second},
+		},
+		{
+			name: "backoff grows up to max backoff",
+			policy: &flow.RetryPolicy{
+				MaxAttempts: 4,
+				Backoff:     flow.Duration(100 * time.Millisecond),
+				MaxBackoff:  flow.Duration(250 * time.Millisecond),
+				Multiplier:  2,
+			},
+			errs:        []error{statusError(500), statusError(500), statusError(500)},
+			wantClasses: []providers.ErrorClass{providers.ErrorClassServerError, providers.ErrorClassServerError, providers.ErrorClassServerError, ""},
+			wantDelays:  []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 250 * time.Millisecond},
+		},
+		{
+			name:        "client errors are not retried",
+			policy:      &flow.RetryPolicy{MaxAttempts: 3},
+			errs:        []error{statusError(400)},
+			wantErr:     true,
+			wantClasses: []providers.ErrorClass{providers.ErrorClassClientError},
+			wantDelays:  []time.Duration{},
+		},
+		{
+			name:        "retry_on leaves out other classes",
+			policy:      &flow.RetryPolicy{MaxAttempts: 3, RetryOn: []providers.ErrorClass{providers.ErrorClassRateLimit}},
+			errs:        []error{statusError(500)},
+			wantErr:     true,
+			wantClasses: []providers.ErrorClass{providers.ErrorClassServerError},
+			wantDelays:  []time.Duration{},
+		},
+		{
+			name:        "retry_on retries listed classes",
+			policy:      &flow.RetryPolicy{MaxAttempts: 3, RetryOn: []providers.ErrorClass{providers.ErrorClassRateLimit}},
+			errs:        []error{statusError(429)},
+			wantClasses: []providers.ErrorClass{providers.ErrorClassRateLimit, ""},
+			wantDelays:  []time.Duration{time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := parseFlow(t, retryFlow)
+			f.Nodes[0].Retry = tt.policy
+
+			e := newTestExecutor(failing(tt.errs...))
+			delays := recordDelays(e)
+			result, err := e.Execute(context.Background(), f, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute error = %v, want error %v", err, tt.wantErr)
+			}
+
+			attempts := nodeResult(t, result, "ask").Attempts
+			classes := make([]providers.ErrorClass, len(attempts))
+			for i, attempt := range attempts {
+				classes[i] = attempt.ErrorClass
+				if attempt.Number != i+1 || attempt.Provider != "fake" || attempt.Model != "m" {
+					t.Errorf("attempt %d = %+v, want number %d of fake model m", i, attempt, i+1)
+				}
+				if (attempt.Error != "") != (attempt.ErrorClass != "") {
+					t.Errorf("attempt %d error %q does not match its class %q", i, attempt.Error, attempt.ErrorClass)
+				}
+			}
+			if !reflect.DeepEqual(classes, tt.wantClasses) {
+				t.Errorf("attempt classes = %v, want %v", classes, tt.wantClasses)
+			}
+			if !reflect.DeepEqual(*delays, tt.wantDelays) {
+				t.Errorf("retry delays = %v, want %v", *delays, tt.wantDelays)
+			}
+		})
+	}
+}
+
+func TestRetryAttemptTimeout(t *testing.T) {
+	f := parseFlow(t, retryFlow)
+	f.Nodes[0].Retry = &flow.RetryPolicy{MaxAttempts: 2, AttemptTimeout: flow.Duration(10 * time.Millisecond)}
+
+	// The first attempt hangs until it times out
+	calls := 0
+	e := newTestExecutor(func(ctx context.Context, req providers.CompletionRequest) (*providers.CompletionResponse, error) {
+		calls++
+		if calls == 1 {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return echo(ctx, req)
+	})
+	recordDelays(e)
+
+	result, err := e.Execute(context.Background(), f, nil)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	attempts := nodeResult(t, result, "ask").Attempts
+	if len(attempts) != 2 {
+		t.Fatalf("made %d attempts, want 2", len(attempts))
+	}
+	if attempts[0].ErrorClass != providers.ErrorClassTimeout {
+		t.Errorf("timed out attempt class = %q, want %q", attempts[0].ErrorClass, providers.ErrorClassTimeout)
+	}
+	if attempts[1].Error != "" {
+		t.Errorf("second attempt failed: %s", attempts[1].Error)
+	}
+}
+
+func TestFallbacksOnlyOnLLMNodes(t *testing.T) {
+	f := parseFlow(t, retryFlow)
+	f.Nodes[0].Type = flow.NodeTypeTemplate
+	f.Nodes[0].Fallbacks = []flow.Fallback{{Model: "n"}}
+
+	_, err := newTestExecutor(echo).Execute(context.Background(), f, nil)
+	if want := "fallbacks are only supported on llm nodes"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("Execute error = %v, want %q", err, want)
+	}
+}
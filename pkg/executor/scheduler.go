@@ -0,0 +1,485 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/broderick/prompt-flow/pkg/expr"
+	"github.com/broderick/prompt-flow/pkg/flow"
+)
+
+// nodeCompletion is sent by a node goroutine once the node has finished
+type nodeCompletion struct {
+	index  int
+	result *flow.NodeResult
+	err    error
+}
+
+// runNodes schedules the nodes in execOrder, starting each node as soon as all of
+// its upstream nodes have finished. Node results are returned in execOrder
+// regardless of the order in which the nodes completed.
+//
+// A node is skipped, rather than run, when its when condition is false or when
+// every node it depends on was skipped. Skipped nodes expose their outputs'
+// declared defaults, if any.
+//
+// When a node with an error handler fails, the handler node is run with the failed
+// node's inputs plus "error" and "failed_node", and the run carries on. Handler nodes
+// are skipped if the node they handle does not fail.
+//
+// When a node fails under the "continue" error policy its failure is recorded and
+// the run carries on. Nodes that depend on it, directly or through other such
+// nodes, are skipped. Every recorded failure is returned in execOrder.
+//
+// Nodes with a result in reuse are not run; the recorded result is used instead.
+//
+// On the first failure under the "fail" policy, or any failure caused by the run's
+// budget, no further nodes are started, running nodes are cancelled and the ID of the
+// failed node is returned along with its error.
+func (e *Executor) runNodes(
+	ctx context.Context,
+	f *flow.Flow,
+	execOrder []*flow.Node,
+	flowInputs map[string]any,
+	reuse map[string]*flow.NodeResult,
+) ([]flow.NodeResult, map[string]map[string]any, []flow.NodeError, string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Build the dependency graph over positions in execOrder
+	positions := make(map[string]int, len(execOrder))
+	for i, node := range execOrder {
+		positions[node.ID] = i
+	}
+
+	graph := f.DependencyGraph()
+	pending := make([]int, len(execOrder)) // number of unfinished upstream nodes
+	upstreams := make([][]int, len(execOrder))
+	dependents := make([][]int, len(execOrder))
+	for i, node := range execOrder {
+		for _, upstream := range graph[node.ID] {
+			j := positions[upstream]
+			pending[i]++
+			upstreams[i] = append(upstreams[i], j)
+			dependents[j] = append(dependents[j], i)
+		}
+	}
+
+	ready := []int{}
+	for i := range execOrder {
+		if pending[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	limit := e.concurrencyLimit(f)
+	results := make([]*flow.NodeResult, len(execOrder))
+	nodeOutputs := make(map[string]map[string]any) // nodeID -> outputName -> value
+	skipped := make(map[string]bool)
+	notTaken := make(map[string]string) // nodeID -> skip reason, for nodes on branches a switch did not take
+	failed := make(map[string]string)   // nodeID -> ID of the failed node it could not run without
+	nodeErrors := make([]error, len(execOrder))
+	nodeInputs := make([]map[string]any, len(execOrder))
+	handlerOf := make(map[string]string)           // error handler ID -> ID of the node it handles
+	errorInputs := make(map[string]map[string]any) // error handler ID -> inputs, once its node has failed
+	for _, node := range execOrder {
+		if handler := node.ErrorHandler(); handler != "" {
+			handlerOf[handler] = node.ID
+		}
+	}
+	completions := make(chan nodeCompletion)
+	running := 0
+
+	var failedNode string
+	var firstErr error
+
+	// release marks a node as finished and queues any dependents that are now ready
+	release := func(i int) {
+		for _, d := range dependents[i] {
+			pending[d]--
+			if pending[d] == 0 {
+				ready = append(ready, d)
+			}
+		}
+		sort.Ints(ready)
+	}
+
+	fail := func(i int, result *flow.NodeResult, err error) {
+		node := execOrder[i]
+		results[i] = result
+		if firstErr != nil {
+			return
+		}
+
+		// Running out of budget, or the run itself being cancelled or timing out,
+		// stops the run whatever the node's error policy
+		stopRun := ctx.Err() != nil || isBudgetExceeded(err)
+
+		// Hand the failure to the node's error handler
+		if handler := node.ErrorHandler(); handler != "" && !stopRun {
+			result.HandledBy = handler
+			failed[node.ID] = node.ID
+			errorInputs[handler] = errorHandlerInputs(node, nodeInputs[i], err)
+			release(i)
+			return
+		}
+
+		nodeErrors[i] = err
+
+		// Carry on past the failure
+		if onErrorPolicy(f, node) == flow.OnErrorContinue && !stopRun {
+			failed[node.ID] = node.ID
+			release(i)
+			return
+		}
+
+		failedNode, firstErr = node.ID, err
+		cancel()
+	}
+
+	// failStart fails a node that could not be started
+	failStart := func(i int, err error) {
+		result := failedResult(execOrder[i], err)
+		e.notify(func(o Observer) { o.NodeEnd(ctx, execOrder[i], result) })
+		fail(i, result, err)
+	}
+
+	skip := func(i int, reason string) {
+		node := execOrder[i]
+		results[i] = skippedResult(node, reason)
+		e.notify(func(o Observer) { o.NodeEnd(ctx, node, results[i]) })
+		nodeOutputs[node.ID] = results[i].Outputs
+		skipped[node.ID] = true
+		release(i)
+	}
+
+	// reuseResult takes a node's result from a previous run as if it had just finished
+	reuseResult := func(i int, recorded *flow.NodeResult) {
+		node := execOrder[i]
+		reused := *recorded
+		reused.Reused = true
+		if reused.Outputs == nil {
+			reused.Outputs = make(map[string]any)
+		}
+		results[i] = &reused
+		nodeOutputs[node.ID] = reused.Outputs
+
+		switch reused.Status {
+		case flow.NodeStatusSkipped:
+			skipped[node.ID] = true
+		case flow.NodeStatusFailed:
+			failed[node.ID] = node.ID
+		default:
+			if node.Switch != nil {
+				for id, reason := range branchesNotTaken(graph, node, reused.Branch) {
+					notTaken[id] = reason
+				}
+			}
+		}
+
+		e.notify(func(o Observer) { o.NodeEnd(ctx, node, results[i]) })
+		release(i)
+	}
+
+	for (firstErr == nil && len(ready) > 0) || running > 0 {
+		// Start as many ready nodes as the concurrency limit allows
+		for firstErr == nil && len(ready) > 0 && (limit <= 0 || running < limit) {
+			i := ready[0]
+			ready = ready[1:]
+			node := execOrder[i]
+
+			if recorded, ok := reuse[node.ID]; ok {
+				reuseResult(i, recorded)
+				continue
+			}
+			if root, ok := failedUpstream(execOrder, upstreams[i], failed, handlerOf[node.ID]); ok {
+				failed[node.ID] = root
+				skip(i, fmt.Sprintf("upstream node %s failed", root))
+				continue
+			}
+			if handled, ok := handlerOf[node.ID]; ok && errorInputs[node.ID] == nil {
+				skip(i, fmt.Sprintf("node %s did not fail", handled))
+				continue
+			}
+			if reason, ok := notTaken[node.ID]; ok {
+				skip(i, reason)
+				continue
+			}
+			if allSkipped(execOrder, upstreams[i], skipped) {
+				skip(i, "all upstream nodes were skipped")
+				continue
+			}
+
+			// Inputs are resolved here so node goroutines never touch nodeOutputs
+			inputData, err := resolveInputs(node, flowInputs, nodeOutputs, skipped)
+			if err != nil {
+				failStart(i, err)
+				continue
+			}
+			for name, val := range errorInputs[node.ID] {
+				if _, ok := inputData[name]; !ok {
+					inputData[name] = val
+				}
+			}
+			nodeInputs[i] = inputData
+
+			run, err := evalWhen(node, inputData, flowInputs, nodeOutputs)
+			if err != nil {
+				failStart(i, err)
+				continue
+			}
+			if !run {
+				skip(i, fmt.Sprintf("condition not met: %s", node.When))
+				continue
+			}
+
+			running++
+			go func() {
+				nodeResult, err := e.executeNode(ctx, f, node, inputData)
+				completions <- nodeCompletion{index: i, result: nodeResult, err: err}
+			}()
+		}
+
+		if running == 0 {
+			continue
+		}
+
+		c := <-completions
+		running--
+
+		if c.err != nil {
+			fail(c.index, c.result, c.err)
+			continue
+		}
+
+		// Store node outputs and release any dependents that are now ready
+		node := execOrder[c.index]
+		results[c.index] = c.result
+		nodeOutputs[node.ID] = c.result.Outputs
+		if node.Switch != nil {
+			for id, reason := range branchesNotTaken(graph, node, c.result.Branch) {
+				notTaken[id] = reason
+			}
+		}
+		release(c.index)
+	}
+
+	nodeResults := []flow.NodeResult{}
+	for _, r := range results {
+		if r != nil {
+			nodeResults = append(nodeResults, *r)
+		}
+	}
+
+	var errs []flow.NodeError
+	for i, err := range nodeErrors {
+		if err != nil {
+			errs = append(errs, flow.NodeError{NodeID: execOrder[i].ID, Error: err.Error()})
+		}
+	}
+
+	return nodeResults, nodeOutputs, errs, failedNode, firstErr
+}
+
+// onErrorPolicy returns the error policy for a node: its own if set, otherwise the
+// flow default, otherwise "fail"
+func onErrorPolicy(f *flow.Flow, node *flow.Node) string {
+	if node.OnError != "" {
+		return node.OnError
+	}
+	if f.Config.OnError != "" {
+		return f.Config.OnError
+	}
+	return flow.OnErrorFail
+}
+
+// failedUpstream returns the failed node that one of a node's upstream nodes failed
+// or was skipped because of, if any. The node handled by an error handler is ignored
+// when checking the handler, given as handles.
+func failedUpstream(execOrder []*flow.Node, upstreams []int, failed map[string]string, handles string) (string, bool) {
+	for _, j := range upstreams {
+		if execOrder[j].ID == handles {
+			continue
+		}
+		if root, ok := failed[execOrder[j].ID]; ok {
+			return root, true
+		}
+	}
+	return "", false
+}
+
+// errorHandlerInputs builds the inputs passed to an error handler: the failed node's
+// own inputs plus its error message and ID
+func errorHandlerInputs(node *flow.Node, inputData map[string]any, err error) map[string]any {
+	handlerInputs := make(map[string]any, len(inputData)+2)
+	for name, val := range inputData {
+		handlerInputs[name] = val
+	}
+	handlerInputs["error"] = err.Error()
+	handlerInputs["failed_node"] = node.ID
+	return handlerInputs
+}
+
+// concurrencyLimit returns the maximum number of nodes that may run at once for
+// the given flow. When both the flow and the executor set a limit the lower one
+// wins. Zero means no limit.
+func (e *Executor) concurrencyLimit(f *flow.Flow) int {
+	limit := f.Config.MaxConcurrency
+	if e.maxConcurrency > 0 && (limit <= 0 || e.maxConcurrency < limit) {
+		limit = e.maxConcurrency
+	}
+	return limit
+}
+
+// branchesNotTaken returns the nodes that only belong to branches the switch node did
+// not route to, mapped to the reason they are skipped. Nodes that are also reachable
+// from the branch that was taken, such as nodes merging the branches, are not included.
+func branchesNotTaken(graph map[string][]string, node *flow.Node, taken string) map[string]string {
+	keep := map[string]bool{}
+	if taken != "" {
+		keep = flow.Descendants(graph, taken)
+	}
+
+	excluded := make(map[string]string)
+	for _, target := range node.Switch.Targets() {
+		if target == taken {
+			continue
+		}
+		for id := range flow.Descendants(graph, target) {
+			if !keep[id] {
+				excluded[id] = fmt.Sprintf("branch not taken by switch %s", node.ID)
+			}
+		}
+	}
+
+	return excluded
+}
+
+// allSkipped reports whether a node has upstream nodes and all of them were skipped
+func allSkipped(execOrder []*flow.Node, upstreams []int, skipped map[string]bool) bool {
+	if len(upstreams) == 0 {
+		return false
+	}
+	for _, j := range upstreams {
+		if !skipped[execOrder[j].ID] {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveInputs builds the input data for a node from the flow inputs and the
+// outputs of upstream nodes. Inputs from skipped nodes take the output's default,
+// or are left unset when there is none.
+func resolveInputs(
+	node *flow.Node,
+	flowInputs map[string]any,
+	nodeOutputs map[string]map[string]any,
+	skipped map[string]bool,
+) (map[string]any, error) {
+	inputData := make(map[string]any)
+
+	for _, input := range node.Inputs {
+		if input.From == "input" {
+			// Get from flow inputs
+			val, ok := flowInputs[input.Name]
+			if !ok {
+				return nil, fmt.Errorf("flow input not provided: %s", input.Name)
+			}
+			inputData[input.Name] = val
+			continue
+		}
+
+		// Get from another node's output
+		nodeID, outputName, ok := flow.SplitReference(input.From)
+		if !ok {
+			return nil, fmt.Errorf("invalid input reference: %s", input.From)
+		}
+
+		outputs, ok := nodeOutputs[nodeID]
+		if !ok {
+			return nil, fmt.Errorf("node outputs not found: %s", nodeID)
+		}
+		val, ok := outputs[outputName]
+		if !ok {
+			if skipped[nodeID] {
+				continue
+			}
+			return nil, fmt.Errorf("output not found: %s.%s", nodeID, outputName)
+		}
+		inputData[input.Name] = val
+	}
+
+	return inputData, nil
+}
+
+// evalWhen evaluates a node's when condition. Dotted references resolve to upstream
+// node outputs, bare names to the node's inputs and then to flow inputs.
+func evalWhen(
+	node *flow.Node,
+	inputData map[string]any,
+	flowInputs map[string]any,
+	nodeOutputs map[string]map[string]any,
+) (bool, error) {
+	if node.When == "" {
+		return true, nil
+	}
+
+	cond, err := expr.Parse(node.When)
+	if err != nil {
+		return false, fmt.Errorf("invalid when condition: %w", err)
+	}
+
+	run, err := cond.EvalBool(func(ref string) (any, bool) {
+		if nodeID, outputName, ok := flow.SplitReference(ref); ok {
+			val, ok := nodeOutputs[nodeID][outputName]
+			return val, ok
+		}
+		if val, ok := inputData[ref]; ok {
+			return val, true
+		}
+		val, ok := flowInputs[ref]
+		return val, ok
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate when condition: %w", err)
+	}
+
+	return run, nil
+}
+
+// failedResult builds the result for a node that failed before it could start
+func failedResult(node *flow.Node, err error) *flow.NodeResult {
+	now := time.Now()
+	return &flow.NodeResult{
+		NodeID:    node.ID,
+		Status:    flow.NodeStatusFailed,
+		Success:   false,
+		Error:     err.Error(),
+		Outputs:   make(map[string]any),
+		StartTime: now,
+		EndTime:   now,
+	}
+}
+
+// skippedResult builds the result for a skipped node, filling in any declared output defaults
+func skippedResult(node *flow.Node, reason string) *flow.NodeResult {
+	now := time.Now()
+	outputs := make(map[string]any)
+	for _, output := range node.Outputs {
+		if output.Default != nil {
+			outputs[output.Name] = output.Default
+		}
+	}
+
+	return &flow.NodeResult{
+		NodeID:     node.ID,
+		Status:     flow.NodeStatusSkipped,
+		SkipReason: reason,
+		Outputs:    outputs,
+		StartTime:  now,
+		EndTime:    now,
+	}
+}
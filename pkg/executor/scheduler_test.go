@@ -0,0 +1,264 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/broderick/prompt-flow/pkg/flow"
+	"github.com/broderick/prompt-flow/pkg/providers"
+)
+
+func TestRunNodesConcurrencyAndOrder(t *testing.T) {
+	// summary is declared first but depends on the others, which finish in a different
+	// order from the one they were declared in
+	f := parseFlow(t, `
+version: "1.0"
+name: concurrency
+config:
+  default_provider: fake
+  default_model: m
+  max_concurrency: 2
+nodes:
+  - id: summary
+    inputs:
+      - {name: a, from: a.text}
+      - {name: b, from: b.text}
+      - {name: c, from: c.text}
+      - {name: d, from: d.text}
+    prompt: "{{.a}}{{.b}}{{.c}}{{.d}}"
+    outputs:
+      - {name: text, to: output}
+  - {id: d, inputs: [], prompt: d, outputs: [{name: text}]}
+  - {id: c, inputs: [], prompt: c, outputs: [{name: text}]}
+  - {id: b, inputs: [], prompt: b, outputs: [{name: text}]}
+  - {id: a, inputs: [], prompt: a, outputs: [{name: text}]}
+`)
+
+	delays := map[string]time.Duration{
+		"d": 40 * time.Millisecond,
+		"c": 5 * time.Millisecond,
+		"b": 30 * time.Millisecond,
+		"a": 10 * time.Millisecond,
+	}
+
+	var mu sync.Mutex
+	running, highWater := 0, 0
+	e := newTestExecutor(func(ctx context.Context, req providers.CompletionRequest) (*providers.CompletionResponse, error) {
+		mu.Lock()
+		running++
+		highWater = max(highWater, running)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+
+		time.Sleep(delays[req.Prompt])
+		return echo(ctx, req)
+	})
+
+	result, err := e.Execute(context.Background(), f, nil)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	if highWater != 2 {
+		t.Errorf("at most %d nodes ran at once, want max_concurrency 2", highWater)
+	}
+	if got, want := resultIDs(result), []string{"d", "c", "b", "a", "summary"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("node results in order %v, want topological order %v", got, want)
+	}
+	if got := result.Outputs["text"]; got != "abcd" {
+		t.Errorf("output text = %v, want %q", got, "abcd")
+	}
+}
+
+func TestRunNodesFirstFailureStopsRun(t *testing.T) {
+	f := parseFlow(t, `
+version: "1.0"
+name: failure
+config:
+  default_provider: fake
+  default_model: m
+  max_concurrency: 2
+nodes:
+  - {id: slow, inputs: [], prompt: slow, outputs: [{name: text}]}
+  - {id: bad, inputs: [], prompt: bad, outputs: [{name: text}]}
+  - {id: later, inputs: [], prompt: later, outputs: [{name: text}]}
+`)
+
+	slowStarted := make(chan struct{})
+	var mu sync.Mutex
+	var slowErr error
+	var called []string
+	e := newTestExecutor(func(ctx context.Context, req providers.CompletionRequest) (*providers.CompletionResponse, error) {
+		mu.Lock()
+		called = append(called, req.Prompt)
+		mu.Unlock()
+
+		switch req.Prompt {
+		case "slow":
+			close(slowStarted)
+			select {
+			case <-ctx.Done():
+				mu.Lock()
+				slowErr = ctx.Err()
+				mu.Unlock()
+				return nil, ctx.Err()
+			case <-time.After(5 * time.Second):
+				return echo(ctx, req)
+			}
+		case "bad":
+			<-slowStarted
+			return nil, errors.New("boom")
+		}
+		return echo(ctx, req)
+	})
+
+	result, err := e.Execute(context.Background(), f, nil)
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("Execute error = %v, want the failure of node bad", err)
+	}
+	if !strings.HasPrefix(result.Error, "node bad failed") {
+		t.Errorf("result error = %q, want it to name node bad", result.Error)
+	}
+
+	if !errors.Is(slowErr, context.Canceled) {
+		t.Errorf("running node saw ctx error %v, want context.Canceled", slowErr)
+	}
+	for _, prompt := range called {
+		if prompt == "later" {
+			t.Errorf("node later was started after the failure")
+		}
+	}
+	if got, want := resultIDs(result), []string{"slow", "bad"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("node results %v, want %v", got, want)
+	}
+}
+
+func TestRunNodesContinuePolicy(t *testing.T) {
+	f := parseFlow(t, `
+version: "1.0"
+name: partial
+config:
+  default_provider: fake
+  default_model: m
+  on_error: continue
+nodes:
+  - {id: ok, inputs: [], prompt: ok, outputs: [{name: ok, to: output}]}
+  - {id: bad, inputs: [], prompt: bad, outputs: [{name: text}]}
+  - {id: child, type: template, inputs: [{name: text, from: bad.text}], prompt: "{{.text}}", outputs: [{name: child, to: output}]}
+  - {id: grandchild, type: template, inputs: [{name: text, from: child.child}], prompt: "{{.text}}", outputs: [{name: grandchild}]}
+  - {id: sibling, type: template, inputs: [{name: text, from: ok.ok}], prompt: "{{.text}} too", outputs: [{name: sibling, to: output}]}
+`)
+
+	e := newTestExecutor(func(ctx context.Context, req providers.CompletionRequest) (*providers.CompletionResponse, error) {
+		if req.Prompt == "bad" {
+			return nil, errors.New("boom")
+		}
+		return echo(ctx, req)
+	})
+
+	result, err := e.Execute(context.Background(), f, nil)
+	if err == nil || err.Error() != "1 of 5 nodes failed: bad" {
+		t.Fatalf("Execute error = %v, want the partial failure of node bad", err)
+	}
+	if result.Success || result.Error != err.Error() {
+		t.Errorf("result success = %v, error %q, want a failed run with error %q", result.Success, result.Error, err)
+	}
+
+	wantErrors := []flow.NodeError{{NodeID: "bad", Error: "LLM call failed: boom"}}
+	if !reflect.DeepEqual(result.Errors, wantErrors) {
+		t.Errorf("result errors = %+v, want %+v", result.Errors, wantErrors)
+	}
+
+	wantStatus := map[string]flow.NodeStatus{
+		"ok":         flow.NodeStatusSucceeded,
+		"bad":        flow.NodeStatusFailed,
+		"child":      flow.NodeStatusSkipped,
+		"grandchild": flow.NodeStatusSkipped,
+		"sibling":    flow.NodeStatusSucceeded,
+	}
+	for id, want := range wantStatus {
+		r := nodeResult(t, result, id)
+		if r.Status != want {
+			t.Errorf("node %s status = %s, want %s", id, r.Status, want)
+		}
+		if want == flow.NodeStatusSkipped && r.SkipReason != "upstream node bad failed" {
+			t.Errorf("node %s skip reason = %q, want it to name node bad", id, r.SkipReason)
+		}
+	}
+
+	wantOutputs := map[string]any{"ok": "ok", "sibling": "ok too"}
+	if !reflect.DeepEqual(result.Outputs, wantOutputs) {
+		t.Errorf("outputs = %v, want the partial outputs %v", result.Outputs, wantOutputs)
+	}
+}
+
+func TestRunNodesErrorHandler(t *testing.T) {
+	const src = `
+version: "1.0"
+name: handled
+config:
+  default_provider: fake
+  default_model: m
+nodes:
+  - id: draft
+    inputs: [{name: ticket, from: input}]
+    prompt: "{{.ticket}}"
+    on_error: apologise
+    outputs: [{name: reply, to: output}]
+  - id: apologise
+    type: template
+    prompt: "{{.failed_node}}: {{.error}} ({{.ticket}})"
+    outputs: [{name: reply, to: output}]
+`
+
+	t.Run("node fails", func(t *testing.T) {
+		e := newTestExecutor(func(ctx context.Context, req providers.CompletionRequest) (*providers.CompletionResponse, error) {
+			return nil, errors.New("boom")
+		})
+		result, err := e.Execute(context.Background(), parseFlow(t, src), map[string]any{"ticket": "late order"})
+		if err != nil {
+			t.Fatalf("Execute: %v, want a handled failure not to fail the run", err)
+		}
+		if !result.Success || len(result.Errors) > 0 {
+			t.Errorf("result success = %v, errors %v, want a successful run", result.Success, result.Errors)
+		}
+
+		draft := nodeResult(t, result, "draft")
+		if draft.Status != flow.NodeStatusFailed || draft.HandledBy != "apologise" {
+			t.Errorf("draft status = %s, handled by %q, want failed and handled by apologise", draft.Status, draft.HandledBy)
+		}
+		if r := nodeResult(t, result, "apologise"); r.Status != flow.NodeStatusSucceeded {
+			t.Errorf("handler status = %s, want %s", r.Status, flow.NodeStatusSucceeded)
+		}
+		if want := "draft: LLM call failed: boom (late order)"; result.Outputs["reply"] != want {
+			t.Errorf("output reply = %v, want %q", result.Outputs["reply"], want)
+		}
+	})
+
+	t.Run("node succeeds", func(t *testing.T) {
+		result, err := newTestExecutor(echo).Execute(context.Background(), parseFlow(t, src), map[string]any{"ticket": "late order"})
+		if err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+
+		if r := nodeResult(t, result, "draft"); r.HandledBy != "" {
+			t.Errorf("draft handled by %q, want no handler", r.HandledBy)
+		}
+		handler := nodeResult(t, result, "apologise")
+		if handler.Status != flow.NodeStatusSkipped || handler.SkipReason != "node draft did not fail" {
+			t.Errorf("handler status = %s (%q), want skipped as draft did not fail", handler.Status, handler.SkipReason)
+		}
+		if result.Outputs["reply"] != "late order" {
+			t.Errorf("output reply = %v, want the draft's reply", result.Outputs["reply"])
+		}
+	})
+}
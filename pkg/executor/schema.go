@@ -0,0 +1,108 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/broderick/prompt-flow/pkg/flow"
+	"github.com/broderick/prompt-flow/pkg/providers"
+	"github.com/broderick/prompt-flow/pkg/schema"
+)
+
+// repairUntilValid checks a response against the node's schema. While it does not
+// match, the target that gave it is re-prompted with the rendered request and the
+// mismatches, up to node.Repairs times. It returns the last response and its
+// parsed value. The mismatches of the last response are left in result.Violations
+// when it fails.
+func (e *Executor) repairUntilValid(
+	ctx context.Context,
+	f *flow.Flow,
+	node *flow.Node,
+	target llmTarget,
+	rendered providers.CompletionRequest,
+	resp *providers.CompletionResponse,
+	s *schema.Schema,
+	result *flow.NodeResult,
+) (*providers.CompletionResponse, any, error) {
+	for repair := 0; ; repair++ {
+		value, violations := checkSchema(s, resp.Content)
+		if len(violations) == 0 {
+			result.Violations = nil
+			return resp, value, nil
+		}
+		result.Violations = violations
+		result.RawOutput = resp.Content
+
+		if repair >= node.Repairs {
+			msg := "response does not match schema"
+			switch {
+			case node.Repairs == 1:
+				msg += " after 1 repair"
+			case node.Repairs > 1:
+				msg += fmt.Sprintf(" after %d repairs", node.Repairs)
+			}
+			return nil, nil, fmt.Errorf("%s: %s", msg, strings.Join(violations, "; "))
+		}
+
+		req := repairRequest(rendered, resp.Content, s, violations)
+		req.Model = target.model
+		req.Settings = target.settings
+		e.notify(func(o Observer) { o.PromptRendered(ctx, node, req.Text()) })
+
+		var err error
+		resp, err = e.completeWithRetry(ctx, node, target.providerName, target.provider, req, retryPolicy(f, node), result)
+		if err != nil {
+			return nil, nil, fmt.Errorf("schema repair %d failed: %w", repair+1, err)
+		}
+		result.Cached = result.Cached && resp.Cached
+		addMetrics(&result.Metrics, flow.NodeMetrics{
+			InputTokens:  resp.InputTokens,
+			OutputTokens: resp.OutputTokens,
+			InputCost:    resp.InputCost,
+			OutputCost:   resp.OutputCost,
+		})
+	}
+}
+
+// checkSchema parses a response as JSON, accepting a fenced code block, and validates
+// it against the schema
+func checkSchema(s *schema.Schema, content string) (any, []string) {
+	var value any
+	if err := parseJSON(content, &value); err != nil {
+		return nil, []string{fmt.Sprintf("response is not valid JSON: %v", err)}
+	}
+	return value, s.Validate(value)
+}
+
+// repairRequest asks the model to correct a response that did not match the schema.
+// Chat requests get the response and the correction as further turns, while a prompt
+// is extended with them.
+func repairRequest(rendered providers.CompletionRequest, response string, s *schema.Schema, violations []string) providers.CompletionRequest {
+	if len(rendered.Messages) == 0 {
+		prompt := rendered.Prompt + "\n\nYour previous response was:\n" + response + "\n\n" + repairInstructions(s, violations)
+		return providers.CompletionRequest{Prompt: prompt}
+	}
+
+	messages := append(slices.Clone(rendered.Messages),
+		providers.Message{Role: providers.RoleAssistant, Content: response},
+		providers.Message{Role: providers.RoleUser, Content: repairInstructions(s, violations)},
+	)
+	return providers.CompletionRequest{Messages: messages}
+}
+
+// repairInstructions describes how a response failed the schema and asks for a corrected one
+func repairInstructions(s *schema.Schema, violations []string) string {
+	var b strings.Builder
+	b.WriteString("Your previous response does not match the required JSON schema:\n")
+	b.WriteString(s.String())
+	b.WriteString("\n\nProblems found:\n")
+	for _, violation := range violations {
+		b.WriteString("- ")
+		b.WriteString(violation)
+		b.WriteString("\n")
+	}
+	b.WriteString("\nReply again with only the corrected JSON.")
+	return b.String()
+}
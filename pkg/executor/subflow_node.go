@@ -0,0 +1,85 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/broderick/prompt-flow/pkg/flow"
+)
+
+// subflowHandler implements the built-in "subflow" node type, which runs another
+// flow file as a single step
+type subflowHandler struct {
+	e *Executor
+}
+
+func (h subflowHandler) Execute(ctx context.Context, req NodeRequest, result *flow.NodeResult) error {
+	child, err := subflowsFrom(ctx).load(req.Flow, req.Node)
+	if err != nil {
+		return err
+	}
+
+	// Node inputs become child flow inputs of the same name
+	childResult, err := h.e.Execute(ctx, child, req.Inputs)
+	if childResult != nil {
+		result.Subflow = childResult
+		result.Metrics = childResult.Metrics
+	}
+	if err != nil {
+		return fmt.Errorf("subflow %s failed: %w", child.Name, err)
+	}
+
+	// Node outputs are read from child flow outputs of the same name
+	var errs []error
+	for _, output := range req.Node.Outputs {
+		val, ok := childResult.Outputs[output.Name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("output %s: not produced by subflow %s", output.Name, child.Name))
+			continue
+		}
+		result.Outputs[output.Name] = val
+	}
+
+	return errors.Join(errs...)
+}
+
+// runSubflows are the child flows loaded and validated with the outermost flow of a
+// run, keyed by the absolute path of their files. Subflow nodes run them, however
+// often, without loading or validating them again.
+type runSubflows map[string]*flow.Flow
+
+type subflowsKey struct{}
+
+// withSubflows returns a context carrying the run's validated child flows
+func withSubflows(ctx context.Context, subflows runSubflows) context.Context {
+	return context.WithValue(ctx, subflowsKey{}, subflows)
+}
+
+// subflowsFrom returns the validated child flows of the run ctx belongs to, or nil
+// outside of one
+func subflowsFrom(ctx context.Context) runSubflows {
+	s, _ := ctx.Value(subflowsKey{}).(runSubflows)
+	return s
+}
+
+// validated reports whether f is one of the validated child flows
+func (s runSubflows) validated(f *flow.Flow) bool {
+	if f.FilePath == "" {
+		return false
+	}
+	path, err := filepath.Abs(f.FilePath)
+	return err == nil && s[path] == f
+}
+
+// load returns the child flow of a subflow node, loading it only if it was not
+// validated with the run
+func (s runSubflows) load(parent *flow.Flow, node *flow.Node) (*flow.Flow, error) {
+	if path, err := parent.SubflowPath(node); err == nil {
+		if child, ok := s[path]; ok {
+			return child, nil
+		}
+	}
+	return parent.LoadSubflow(node)
+}
@@ -0,0 +1,96 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/broderick/prompt-flow/pkg/flow"
+	"github.com/broderick/prompt-flow/pkg/providers"
+)
+
+// subflowParent runs the child flow once per ticket
+const subflowParent = `
+version: "1.0"
+name: parent
+config:
+  default_provider: fake
+  default_model: m
+inputs:
+  - {name: tickets, type: list}
+nodes:
+  - id: classify
+    type: map
+    inputs:
+      - {name: tickets, from: input}
+    map:
+      max_concurrency: 1
+      as: ticket
+      node:
+        type: subflow
+        subflow: {path: child.flow.yaml}
+        outputs: [{name: label}]
+    outputs:
+      - {name: labels, to: output}
+`
+
+const subflowChild = `
+version: "1.0"
+name: child
+config:
+  default_provider: fake
+  default_model: m
+nodes:
+  - {id: label, inputs: [{name: ticket, from: input}], prompt: "PREFIX {{.ticket}}", outputs: [{name: label, to: output}]}
+`
+
+func TestSubflowLoadedOncePerRun(t *testing.T) {
+	dir := t.TempDir()
+	childPath := filepath.Join(dir, "child.flow.yaml")
+	writeChild := func(prefix string) {
+		t.Helper()
+		src := []byte(strings.Replace(subflowChild, "PREFIX", prefix, 1))
+		if err := os.WriteFile(childPath, src, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeChild("old")
+
+	// Every call breaks the child flow file, which a run must not read again
+	e := newTestExecutor(func(ctx context.Context, req providers.CompletionRequest) (*providers.CompletionResponse, error) {
+		if err := os.WriteFile(childPath, []byte("not: [a flow"), 0644); err != nil {
+			return nil, err
+		}
+		return echo(ctx, req)
+	})
+
+	f, err := flow.ParseBytes([]byte(subflowParent), filepath.Join(dir, "parent.flow.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inputs := map[string]any{"tickets": []any{"a", "b", "c"}}
+	result, err := e.Execute(context.Background(), f, inputs)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	want := []any{"old a", "old b", "old c"}
+	if got := result.Outputs["labels"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("labels = %v, want %v from the child flow loaded at the start of the run", got, want)
+	}
+
+	// The next run loads the child flow again
+	writeChild("new")
+	e = newTestExecutor(echo)
+	result, err = e.Execute(context.Background(), f, inputs)
+	if err != nil {
+		t.Fatalf("second Execute: %v", err)
+	}
+	want = []any{"new a", "new b", "new c"}
+	if got := result.Outputs["labels"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("labels in the next run = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,52 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/broderick/prompt-flow/pkg/flow"
+)
+
+// switchHandler implements the built-in "switch" node type. It picks the branch to
+// run and records it in NodeResult.Branch; the scheduler then skips the others.
+type switchHandler struct{}
+
+func (h switchHandler) Execute(ctx context.Context, req NodeRequest, result *flow.NodeResult) error {
+	cfg := req.Node.Switch
+
+	inputName := cfg.Value
+	if inputName == "" {
+		inputName = req.Node.Inputs[0].Name
+	}
+	value := strings.TrimSpace(fmt.Sprint(req.Inputs[inputName]))
+
+	// Check cases in a stable order so the result never depends on map iteration
+	caseValues := make([]string, 0, len(cfg.Cases))
+	for caseValue := range cfg.Cases {
+		caseValues = append(caseValues, caseValue)
+	}
+	sort.Strings(caseValues)
+
+	matched := ""
+	for _, caseValue := range caseValues {
+		if strings.EqualFold(strings.TrimSpace(caseValue), value) {
+			matched = caseValue
+			result.Branch = cfg.Cases[caseValue]
+			break
+		}
+	}
+	if result.Branch == "" && cfg.Default != "" {
+		matched = "default"
+		result.Branch = cfg.Default
+	}
+
+	result.RawOutput = value
+	if len(req.Node.Outputs) > 0 {
+		// The first output receives the case that matched, if any
+		result.Outputs[req.Node.Outputs[0].Name] = matched
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package executor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/broderick/prompt-flow/pkg/flow"
+)
+
+const switchFlow = `
+version: "1.0"
+name: routing
+inputs:
+  - name: department
+nodes:
+  - id: route
+    type: switch
+    inputs:
+      - {name: department, from: input}
+    switch:
+      cases:
+        billing: billing
+        engineering: engineering
+      default: general
+    outputs:
+      - name: matched
+
+  - {id: billing, type: template, inputs: [{name: department, from: input}], prompt: "billing reply", outputs: [{name: reply}]}
+  - {id: refund, type: template, inputs: [{name: reply, from: billing.reply}], prompt: "{{.reply}} with refund", outputs: [{name: reply}]}
+  - {id: engineering, type: template, inputs: [{name: department, from: input}], prompt: "engineering reply", outputs: [{name: reply}]}
+  - {id: general, type: template, inputs: [{name: department, from: input}], prompt: "general reply", outputs: [{name: reply}]}
+
+  - id: final
+    type: template
+    inputs:
+      - {name: refund, from: refund.reply}
+      - {name: engineering, from: engineering.reply}
+      - {name: general, from: general.reply}
+    prompt: "{{with .refund}}{{.}}{{end}}{{with .engineering}}{{.}}{{end}}{{with .general}}{{.}}{{end}}"
+    outputs:
+      - {name: reply, to: output}
+`
+
+func TestSwitchRouting(t *testing.T) {
+	tests := []struct {
+		name        string
+		department  string
+		wantBranch  string
+		wantMatched string
+		wantRun     []string
+		wantSkipped []string
+		wantReply   string
+	}{
+		{
+			name:        "case",
+			department:  "billing",
+			wantBranch:  "billing",
+			wantMatched: "billing",
+			wantRun:     []string{"billing", "refund"},
+			wantSkipped: []string{"engineering", "general"},
+			wantReply:   "billing reply with refund",
+		},
+		{
+			name:        "case matches ignoring case and whitespace",
+			department:  " Engineering\n",
+			wantBranch:  "engineering",
+			wantMatched: "engineering",
+			wantRun:     []string{"engineering"},
+			wantSkipped: []string{"billing", "refund", "general"},
+			wantReply:   "engineering reply",
+		},
+		{
+			name:        "default",
+			department:  "sales",
+			wantBranch:  "general",
+			wantMatched: "default",
+			wantRun:     []string{"general"},
+			wantSkipped: []string{"billing", "refund", "engineering"},
+			wantReply:   "general reply",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestExecutor(echo)
+			result, err := e.Execute(context.Background(), parseFlow(t, switchFlow), map[string]any{"department": tt.department})
+			if err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+
+			route := nodeResult(t, result, "route")
+			if route.Branch != tt.wantBranch {
+				t.Errorf("route branch = %q, want %q", route.Branch, tt.wantBranch)
+			}
+			if got := route.Outputs["matched"]; got != tt.wantMatched {
+				t.Errorf("route output matched = %v, want %q", got, tt.wantMatched)
+			}
+
+			for _, id := range tt.wantRun {
+				if r := nodeResult(t, result, id); r.Status != flow.NodeStatusSucceeded {
+					t.Errorf("node %s status = %s, want %s", id, r.Status, flow.NodeStatusSucceeded)
+				}
+			}
+			for _, id := range tt.wantSkipped {
+				r := nodeResult(t, result, id)
+				if r.Status != flow.NodeStatusSkipped {
+					t.Errorf("node %s status = %s, want %s", id, r.Status, flow.NodeStatusSkipped)
+				}
+				if want := "branch not taken by switch route"; r.SkipReason != want {
+					t.Errorf("node %s skip reason = %q, want %q", id, r.SkipReason, want)
+				}
+			}
+
+			// The merge node runs with the inputs from skipped branches left unset
+			if r := nodeResult(t, result, "final"); r.Status != flow.NodeStatusSucceeded {
+				t.Errorf("merge node status = %s, want %s", r.Status, flow.NodeStatusSucceeded)
+			}
+			if got := result.Outputs["reply"]; got != tt.wantReply {
+				t.Errorf("output reply = %v, want %q", got, tt.wantReply)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/broderick/prompt-flow/pkg/flow"
+)
+
+// TimeoutError is returned when a node, or the whole flow, runs past its timeout
+type TimeoutError struct {
+	NodeID  string // Empty when the flow timeout was reached
+	Timeout time.Duration
+	Err     error
+}
+
+func (e *TimeoutError) Error() string {
+	if e.NodeID == "" {
+		return fmt.Sprintf("flow timed out after %v", e.Timeout)
+	}
+	return fmt.Sprintf("node %s timed out after %v", e.NodeID, e.Timeout)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// withTimeout derives a context that expires after timeout. A zero timeout returns
+// the parent context unchanged.
+func withTimeout(ctx context.Context, timeout flow.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, time.Duration(timeout))
+}
+
+// timeoutError wraps err in a TimeoutError if ctx expired because of its own timeout
+// rather than because parent was cancelled or expired first
+func timeoutError(parent, ctx context.Context, nodeID string, timeout flow.Duration, err error) error {
+	if timeout <= 0 || parent.Err() != nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return &TimeoutError{NodeID: nodeID, Timeout: time.Duration(timeout), Err: err}
+}
@@ -0,0 +1,74 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/broderick/prompt-flow/pkg/flow"
+	"github.com/broderick/prompt-flow/pkg/providers"
+)
+
+// hang blocks every request until its context is done
+func hang(ctx context.Context, req providers.CompletionRequest) (*providers.CompletionResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestTimeoutError(t *testing.T) {
+	tests := []struct {
+		name        string
+		flowTimeout time.Duration
+		nodeTimeout time.Duration
+		wantNode    string
+		wantTimeout time.Duration
+		wantError   string
+	}{
+		{
+			name:        "node timeout",
+			nodeTimeout: 10 * time.Millisecond,
+			wantNode:    "ask",
+			wantTimeout: 10 * time.Millisecond,
+			wantError:   "node ask timed out after 10ms",
+		},
+		{
+			name:        "flow timeout",
+			flowTimeout: 10 * time.Millisecond,
+			wantTimeout: 10 * time.Millisecond,
+			wantError:   "node ask failed: flow timed out after 10ms",
+		},
+		{
+			name:        "flow timeout before the node's",
+			flowTimeout: 10 * time.Millisecond,
+			nodeTimeout: time.Minute,
+			wantTimeout: 10 * time.Millisecond,
+			wantError:   "node ask failed: flow timed out after 10ms",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := parseFlow(t, retryFlow)
+			f.Config.Timeout = flow.Duration(tt.flowTimeout)
+			f.Nodes[0].Timeout = flow.Duration(tt.nodeTimeout)
+
+			result, err := newTestExecutor(hang).Execute(context.Background(), f, nil)
+
+			var timeoutErr *TimeoutError
+			if !errors.As(err, &timeoutErr) {
+				t.Fatalf("Execute error = %v, want a TimeoutError", err)
+			}
+			if timeoutErr.NodeID != tt.wantNode || timeoutErr.Timeout != tt.wantTimeout {
+				t.Errorf("TimeoutError for node %q after %v, want node %q after %v",
+					timeoutErr.NodeID, timeoutErr.Timeout, tt.wantNode, tt.wantTimeout)
+			}
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("Execute error = %v, want it to wrap context.DeadlineExceeded", err)
+			}
+			if result.Error != tt.wantError {
+				t.Errorf("result error = %q, want %q", result.Error, tt.wantError)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package executor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/broderick/prompt-flow/pkg/flow"
+)
+
+// whenFlow runs reply only when its condition on the classify output and the
+// department input holds
+const whenFlow = `
+version: "1.0"
+name: conditional
+inputs:
+  - name: urgency
+  - name: department
+nodes:
+  - {id: classify, type: template, inputs: [{name: urgency, from: input}], prompt: "{{.urgency}}", outputs: [{name: urgency}]}
+  - id: reply
+    type: template
+    inputs: [{name: urgency, from: classify.urgency}]
+    when: 'WHEN'
+    prompt: "escalated"
+    outputs: [{name: text, to: output}]
+`
+
+func TestWhen(t *testing.T) {
+	tests := []struct {
+		name       string
+		when       string
+		urgency    string
+		wantStatus flow.NodeStatus
+	}{
+		{name: "true", when: `classify.urgency == "high" && department != "sales"`, urgency: "high", wantStatus: flow.NodeStatusSucceeded},
+		{name: "false", when: `classify.urgency == "high" && department != "sales"`, urgency: "low", wantStatus: flow.NodeStatusSkipped},
+		{name: "equality is exact", when: `classify.urgency == "high"`, urgency: " High\n", wantStatus: flow.NodeStatusSkipped},
+		{name: "fold equality ignores case and white space", when: `classify.urgency ~= "high"`, urgency: " High\n", wantStatus: flow.NodeStatusSucceeded},
+		{name: "node input by bare name", when: `urgency == "high"`, urgency: "high", wantStatus: flow.NodeStatusSucceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := parseFlow(t, strings.Replace(whenFlow, "WHEN", tt.when, 1))
+			result, err := newTestExecutor(echo).Execute(context.Background(), f, map[string]any{"urgency": tt.urgency, "department": "billing"})
+			if err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+			if r := nodeResult(t, result, "reply"); r.Status != tt.wantStatus {
+				t.Errorf("reply status = %s, want %s", r.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestWhenReferencesAreValidated(t *testing.T) {
+	tests := []struct {
+		name    string
+		when    string
+		wantErr string
+	}{
+		{name: "unknown bare name", when: `departmnet == "sales"`, wantErr: "unknown reference: departmnet"},
+		{name: "unknown node", when: `classifier.urgency == "high"`, wantErr: "referenced node does not exist: classifier"},
+		{name: "unknown output", when: `classify.level == "high"`, wantErr: "referenced output does not exist: classify.level"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := parseFlow(t, strings.Replace(whenFlow, "WHEN", tt.when, 1))
+			_, err := newTestExecutor(echo).Execute(context.Background(), f, map[string]any{"urgency": "high", "department": "billing"})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Execute error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
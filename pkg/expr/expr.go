@@ -0,0 +1,195 @@
+// Package expr implements the small expression language used by `when:` conditions
+// in flow definitions.
+//
+// An expression compares values taken from node inputs and upstream node outputs:
+//
+//	classify_urgency.urgency_level == "high" && confidence >= 0.8
+//
+// Supported syntax:
+//   - References: a bare name (e.g. ticket_text) or a dotted "node_id.output_name"
+//   - Literals: "double" or 'single' quoted strings, numbers, true, false and null
+//   - Comparison: ==, !=, <, <=, >, >=, and ~= for text equal ignoring case
+//   - Logic: &&, || and ! with the usual precedence, plus parentheses for grouping
+//
+// A reference that cannot be resolved evaluates to null. Values are compared as
+// numbers when both sides can be read as numbers, otherwise strings and booleans
+// are compared as-is. The ~= operator instead compares text with leading and trailing
+// white space trimmed and case ignored, the way a switch node matches its cases, so
+// an LLM output of "High\n" ~= "high". In a logical context null, false, "" and 0 are
+// false and every other value is true.
+package expr
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Lookup resolves a reference to its value. It returns false if the reference is unknown.
+type Lookup func(ref string) (any, bool)
+
+// Expr is a parsed expression
+type Expr struct {
+	src  string
+	root node
+}
+
+// Parse parses an expression
+func Parse(src string) (*Expr, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %s at position %d", tok, tok.pos)
+	}
+
+	return &Expr{src: src, root: root}, nil
+}
+
+// String returns the source text of the expression
+func (e *Expr) String() string {
+	return e.src
+}
+
+// References returns every reference used in the expression, in order of first use
+func (e *Expr) References() []string {
+	seen := make(map[string]bool)
+	refs := []string{}
+	e.root.walk(func(n node) {
+		if r, ok := n.(refNode); ok && !seen[r.name] {
+			seen[r.name] = true
+			refs = append(refs, r.name)
+		}
+	})
+	return refs
+}
+
+// Eval evaluates the expression and returns its value
+func (e *Expr) Eval(lookup Lookup) (any, error) {
+	return e.root.eval(lookup)
+}
+
+// EvalBool evaluates the expression and returns its truthiness
+func (e *Expr) EvalBool(lookup Lookup) (bool, error) {
+	val, err := e.Eval(lookup)
+	if err != nil {
+		return false, err
+	}
+	return Truthy(val), nil
+}
+
+// Truthy reports whether a value counts as true in a logical context
+func Truthy(val any) bool {
+	switch v := val.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	}
+	if n, ok := toNumber(val); ok {
+		return n != 0
+	}
+	return true
+}
+
+// Equal reports whether two values are equal using the expression language's comparison rules
+func Equal(a, b any) bool {
+	if an, ok := toNumber(a); ok {
+		if bn, ok := toNumber(b); ok {
+			return an == bn
+		}
+	}
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+// EqualFold reports whether two values are equal the way the ~= operator compares
+// them: like Equal, except that text is compared ignoring leading and trailing white
+// space and case
+func EqualFold(a, b any) bool {
+	if Equal(a, b) {
+		return true
+	}
+	if a == nil || b == nil {
+		return false
+	}
+	return strings.EqualFold(strings.TrimSpace(fmt.Sprint(a)), strings.TrimSpace(fmt.Sprint(b)))
+}
+
+func compare(op string, a, b any) (bool, error) {
+	if an, ok := toNumber(a); ok {
+		if bn, ok := toNumber(b); ok {
+			switch op {
+			case "<":
+				return an < bn, nil
+			case "<=":
+				return an <= bn, nil
+			case ">":
+				return an > bn, nil
+			default:
+				return an >= bn, nil
+			}
+		}
+	}
+
+	as, aok := a.(string)
+	bs, bok := b.(string)
+	if !aok || !bok {
+		return false, fmt.Errorf("cannot compare %v and %v with %s", formatValue(a), formatValue(b), op)
+	}
+	switch op {
+	case "<":
+		return as < bs, nil
+	case "<=":
+		return as <= bs, nil
+	case ">":
+		return as > bs, nil
+	default:
+		return as >= bs, nil
+	}
+}
+
+// toNumber converts numeric values, and strings holding a number, to float64
+func toNumber(val any) (float64, bool) {
+	switch v := val.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func formatValue(val any) string {
+	if s, ok := val.(string); ok {
+		return strconv.Quote(s)
+	}
+	if val == nil {
+		return "null"
+	}
+	return fmt.Sprint(val)
+}
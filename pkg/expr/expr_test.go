@@ -0,0 +1,198 @@
+package expr
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	values := map[string]any{
+		"urgency":          "high",
+		"padded":           "  High\n",
+		"confidence":       0.85,
+		"count":            3,
+		"count_text":       "3",
+		"empty":            "",
+		"flag":             true,
+		"none":             nil,
+		"classify.level":   "medium",
+		"route-to.team":    "billing",
+		"score_with_space": " 0.9\n",
+	}
+	lookup := func(ref string) (any, bool) {
+		v, ok := values[ref]
+		return v, ok
+	}
+
+	tests := []struct {
+		name string
+		src  string
+		want any
+	}{
+		// Literals and references
+		{name: "double quoted string", src: `"high"`, want: "high"},
+		{name: "single quoted string", src: `'it'`, want: "it"},
+		{name: "escaped quote", src: `"say \"hi\""`, want: `say "hi"`},
+		{name: "number", src: `42`, want: 42.0},
+		{name: "negative number", src: `-1.5`, want: -1.5},
+		{name: "true", src: `true`, want: true},
+		{name: "false", src: `false`, want: false},
+		{name: "null", src: `null`, want: nil},
+		{name: "bare reference", src: `urgency`, want: "high"},
+		{name: "dotted reference", src: `classify.level`, want: "medium"},
+		{name: "reference with hyphen", src: `route-to.team`, want: "billing"},
+		{name: "unknown reference", src: `missing`, want: nil},
+		{name: "unknown reference is null", src: `missing == null`, want: true},
+		{name: "unknown reference is not a string", src: `missing == ""`, want: false},
+
+		// Equality
+		{name: "string equal", src: `urgency == "high"`, want: true},
+		{name: "string not equal", src: `urgency != "low"`, want: true},
+		{name: "string equality is exact", src: `urgency == "HIGH"`, want: false},
+		{name: "string equality keeps white space", src: `padded == "High"`, want: false},
+		{name: "string inequality keeps white space", src: `padded != "High"`, want: true},
+		{name: "fold equality ignores case", src: `urgency ~= "HIGH"`, want: true},
+		{name: "fold equality trims operands", src: `padded ~= "high"`, want: true},
+		{name: "fold equality of different text", src: `padded ~= "low"`, want: false},
+		{name: "fold equality of numbers", src: `score_with_space ~= 0.90`, want: true},
+		{name: "fold equality of null", src: `missing ~= ""`, want: false},
+		{name: "fold equality of bool and text", src: `flag ~= "True"`, want: true},
+		{name: "number equal", src: `count == 3`, want: true},
+		{name: "numeric text equals number", src: `count_text == 3.0`, want: true},
+		{name: "padded numeric text equals number", src: `score_with_space == 0.9`, want: true},
+		{name: "number and string", src: `count == "three"`, want: false},
+		{name: "bool equal", src: `flag == true`, want: true},
+		{name: "bool and text", src: `flag == "true"`, want: true},
+		{name: "bool and text differing in case", src: `flag == "True"`, want: false},
+		{name: "null equal null", src: `none == null`, want: true},
+		{name: "null not equal false", src: `none == false`, want: false},
+		{name: "null not equal empty string", src: `none != ""`, want: true},
+
+		// Ordering
+		{name: "number less", src: `confidence < 0.9`, want: true},
+		{name: "number less or equal", src: `count <= 3`, want: true},
+		{name: "number greater", src: `count > 3`, want: false},
+		{name: "number greater or equal", src: `confidence >= 0.8`, want: true},
+		{name: "numeric text compared as number", src: `count_text > 10`, want: false},
+		{name: "padded numeric text compared as number", src: `score_with_space > 0.5`, want: true},
+		{name: "string ordering", src: `"apple" < "banana"`, want: true},
+		{name: "string ordering is exact", src: `"Banana" > "apple"`, want: false},
+
+		// Logic
+		{name: "and", src: `urgency == "high" && count == 3`, want: true},
+		{name: "and false", src: `urgency == "high" && count == 4`, want: false},
+		{name: "or", src: `urgency == "low" || count == 3`, want: true},
+		{name: "or false", src: `urgency == "low" || count == 4`, want: false},
+		{name: "not", src: `!flag`, want: false},
+		{name: "double not", src: `!!flag`, want: true},
+		{name: "not null", src: `!missing`, want: true},
+		{name: "not empty string", src: `!empty`, want: true},
+		{name: "not zero", src: `!0`, want: true},
+		{name: "not parenthesised comparison", src: `!(count == 3)`, want: false},
+		{name: "and short-circuits an error", src: `false && flag < 1`, want: false},
+		{name: "or short-circuits an error", src: `true || flag < 1`, want: true},
+		{name: "and yields a bool", src: `urgency && count`, want: true},
+
+		// Precedence
+		{name: "and binds tighter than or", src: `true || false && false`, want: true},
+		{name: "and binds tighter than or on the left", src: `false && false || true`, want: true},
+		{name: "parentheses override precedence", src: `(true || false) && false`, want: false},
+		{name: "comparison binds tighter than and", src: `count == 3 && urgency == "high"`, want: true},
+		{name: "not binds tighter than comparison", src: `!flag == false`, want: true},
+		{name: "nested parentheses", src: `((count == 3) && (!(urgency == "low")))`, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := Parse(tt.src)
+			if err != nil {
+				t.Fatalf("Parse(%q) unexpected error: %v", tt.src, err)
+			}
+			got, err := e.Eval(lookup)
+			if err != nil {
+				t.Fatalf("Eval(%q) unexpected error: %v", tt.src, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Eval(%q) = %#v, want %#v", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	lookup := func(ref string) (any, bool) {
+		if ref == "flag" {
+			return true, true
+		}
+		return nil, false
+	}
+
+	tests := []struct {
+		src     string
+		wantErr string
+	}{
+		{src: `flag < 1`, wantErr: "cannot compare true and 1 with <"},
+		{src: `"a" > 1`, wantErr: `cannot compare "a" and 1 with >`},
+		{src: `missing >= "a"`, wantErr: `cannot compare null and "a" with >=`},
+		{src: `true && flag <= "x"`, wantErr: `cannot compare true and "x" with <=`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			e, err := Parse(tt.src)
+			if err != nil {
+				t.Fatalf("Parse(%q) unexpected error: %v", tt.src, err)
+			}
+			_, err = e.Eval(lookup)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Eval(%q) error = %v, want it to contain %q", tt.src, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src     string
+		wantErr string
+	}{
+		{src: ``, wantErr: "unexpected end of expression at position 0"},
+		{src: `   `, wantErr: "unexpected end of expression at position 3"},
+		{src: `"high`, wantErr: "unterminated string starting at position 0"},
+		{src: `a == 'b`, wantErr: "unterminated string starting at position 5"},
+		{src: `a = b`, wantErr: `unexpected character '=' at position 2`},
+		{src: `a & b`, wantErr: `unexpected character '&' at position 2`},
+		{src: `a == #`, wantErr: `unexpected character '#' at position 5`},
+		{src: `a ==`, wantErr: "unexpected end of expression at position 4"},
+		{src: `&& a`, wantErr: `unexpected "&&" at position 0`},
+		{src: `a b`, wantErr: `unexpected "b" at position 2`},
+		{src: `a == b == c`, wantErr: `unexpected "==" at position 7`},
+		{src: `(a == b`, wantErr: `expected ")" at position 7, found end of expression`},
+		{src: `(a == b c)`, wantErr: `expected ")" at position 8, found "c"`},
+		{src: `a)`, wantErr: `unexpected ")" at position 1`},
+		{src: `()`, wantErr: `unexpected ")" at position 1`},
+		{src: `1.2.3`, wantErr: `invalid number "1.2.3" at position 0`},
+		{src: `!`, wantErr: "unexpected end of expression at position 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := Parse(tt.src)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Parse(%q) error = %v, want it to contain %q", tt.src, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReferences(t *testing.T) {
+	e, err := Parse(`a.x == "y" && (b > 1 || !a.x) && c != null`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a.x", "b", "c"}
+	if got := e.References(); !reflect.DeepEqual(got, want) {
+		t.Errorf("References() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,228 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// node is a node in the expression's syntax tree
+type node interface {
+	eval(lookup Lookup) (any, error)
+	walk(fn func(node))
+}
+
+type literalNode struct {
+	value any
+}
+
+func (n literalNode) eval(Lookup) (any, error) { return n.value, nil }
+func (n literalNode) walk(fn func(node))       { fn(n) }
+
+type refNode struct {
+	name string
+}
+
+func (n refNode) eval(lookup Lookup) (any, error) {
+	if lookup == nil {
+		return nil, nil
+	}
+	val, _ := lookup(n.name)
+	return val, nil
+}
+
+func (n refNode) walk(fn func(node)) { fn(n) }
+
+type notNode struct {
+	operand node
+}
+
+func (n notNode) eval(lookup Lookup) (any, error) {
+	val, err := n.operand.eval(lookup)
+	if err != nil {
+		return nil, err
+	}
+	return !Truthy(val), nil
+}
+
+func (n notNode) walk(fn func(node)) {
+	fn(n)
+	n.operand.walk(fn)
+}
+
+type binaryNode struct {
+	op          string
+	left, right node
+}
+
+func (n binaryNode) eval(lookup Lookup) (any, error) {
+	left, err := n.left.eval(lookup)
+	if err != nil {
+		return nil, err
+	}
+
+	// Short-circuit the logical operators
+	switch n.op {
+	case "&&":
+		if !Truthy(left) {
+			return false, nil
+		}
+	case "||":
+		if Truthy(left) {
+			return true, nil
+		}
+	}
+
+	right, err := n.right.eval(lookup)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "&&", "||":
+		return Truthy(right), nil
+	case "==":
+		return Equal(left, right), nil
+	case "!=":
+		return !Equal(left, right), nil
+	case "~=":
+		return EqualFold(left, right), nil
+	default:
+		return compare(n.op, left, right)
+	}
+}
+
+func (n binaryNode) walk(fn func(node)) {
+	fn(n)
+	n.left.walk(fn)
+	n.right.walk(fn)
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) acceptOperator(ops ...string) (string, bool) {
+	tok := p.peek()
+	if tok.kind != tokenOperator {
+		return "", false
+	}
+	for _, op := range ops {
+		if tok.text == op {
+			p.next()
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.acceptOperator("||"); !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: "||", left: left, right: right}
+	}
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.acceptOperator("&&"); !ok {
+			return left, nil
+		}
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: "&&", left: left, right: right}
+	}
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.acceptOperator("==", "!=", "~=", "<", "<=", ">", ">=")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return binaryNode{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if _, ok := p.acceptOperator("!"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, fmt.Errorf("expected \")\" at position %d, found %s", closing.pos, closing)
+		}
+		return inner, nil
+
+	case tokenString:
+		return literalNode{value: tok.text}, nil
+
+	case tokenNumber:
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at position %d", tok.text, tok.pos)
+		}
+		return literalNode{value: f}, nil
+
+	case tokenIdent:
+		switch tok.text {
+		case "true":
+			return literalNode{value: true}, nil
+		case "false":
+			return literalNode{value: false}, nil
+		case "null":
+			return literalNode{value: nil}, nil
+		}
+		return refNode{name: tok.text}, nil
+	}
+
+	return nil, fmt.Errorf("unexpected %s at position %d", tok, tok.pos)
+}
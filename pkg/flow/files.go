@@ -0,0 +1,240 @@
+package flow
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// LoadPrompt returns the prompt of a node: its prompt, or the contents of its
+// prompt_file, read relative to the flow file.
+func (f *Flow) LoadPrompt(node *Node) (string, error) {
+	if node.PromptFile == "" {
+		return node.Prompt, nil
+	}
+	data, err := os.ReadFile(f.ResolvePath(node.PromptFile))
+	if err != nil {
+		return "", fmt.Errorf("failed to read prompt file: %w", err)
+	}
+	return string(data), nil
+}
+
+// LoadPartials reads the templates in the flow's partials directory, relative to the
+// flow file, keyed by file name without the extension. It returns nil if the flow
+// has no partials directory.
+func (f *Flow) LoadPartials() (map[string]string, error) {
+	if f.Partials == "" {
+		return nil, nil
+	}
+
+	dir := f.ResolvePath(f.Partials)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read partials directory: %w", err)
+	}
+
+	partials := make(map[string]string)
+	files := make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if other, ok := files[name]; ok {
+			return nil, fmt.Errorf("%s and %s both define partial %q", other, entry.Name(), name)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read partial: %w", err)
+		}
+		files[name] = entry.Name()
+		partials[name] = string(data)
+	}
+
+	return partials, nil
+}
+
+// ParseTemplate parses text as a template of the flow. The template can use the
+// built-in template functions, the given funcs and, with {{template "name" .}}, the
+// flow's partials, which are read from the partials directory.
+func (f *Flow) ParseTemplate(name, text string, funcs template.FuncMap) (*template.Template, error) {
+	partials, err := f.ParsePartials(funcs)
+	if err != nil {
+		return nil, err
+	}
+	return ParseWithPartials(partials, name, text)
+}
+
+// ParsePartials reads and parses the flow's partials into a template set using the
+// built-in template functions and the given funcs. Pass the set to ParseWithPartials
+// to parse several templates without reading the partials again.
+func (f *Flow) ParsePartials(funcs template.FuncMap) (*template.Template, error) {
+	partials, err := f.LoadPartials()
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl := template.New("").Funcs(TemplateFuncs()).Funcs(funcs)
+	for _, partial := range sortedKeys(partials) {
+		if _, err := tmpl.New(partial).Parse(partials[partial]); err != nil {
+			return nil, fmt.Errorf("partial %s: %w", partial, err)
+		}
+	}
+	return tmpl, nil
+}
+
+// ParseWithPartials parses text as a template named name alongside a copy of the
+// partials returned by ParsePartials, which are left unchanged
+func ParseWithPartials(partials *template.Template, name, text string) (*template.Template, error) {
+	tmpl, err := partials.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return tmpl.New(name).Parse(text)
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// validateTemplates checks that the prompt files and partials can be read, that they
+// and the templates of every node parse using the built-in functions and funcs, and
+// that every {{template}} used by a prompt or message names a partial or a template
+// defined in the same text
+func validateTemplates(flow *Flow, funcs template.FuncMap) error {
+	partials, err := flow.LoadPartials()
+	if err != nil {
+		return ValidationError{Field: "partials", Message: err.Error()}
+	}
+	for _, name := range sortedKeys(partials) {
+		if err := validateTemplate("partials", name, partials[name], funcs); err != nil {
+			return err
+		}
+	}
+	parsed, err := flow.ParsePartials(funcs)
+	if err != nil {
+		return ValidationError{Field: "partials", Message: err.Error()}
+	}
+
+	for _, node := range flow.Nodes {
+		for _, n := range []*Node{&node, nestedNode(&node)} {
+			if n == nil {
+				continue
+			}
+
+			prefix := fmt.Sprintf("node %s, ", node.ID)
+			if n != &node {
+				prefix += "map.node."
+			}
+			switch n.TypeName() {
+			case NodeTypeLLM, NodeTypeTemplate:
+				prompt, err := flow.LoadPrompt(n)
+				if err != nil {
+					return ValidationError{Field: prefix + "prompt_file", Message: err.Error()}
+				}
+				if prompt == "" && n.PromptFile != "" {
+					return ValidationError{Field: prefix + "prompt_file", Message: fmt.Sprintf("prompt file %s is empty", n.PromptFile)}
+				}
+				if prompt != "" {
+					if err := validateTemplateRefs(parsed, prefix+"prompt", node.ID, prompt); err != nil {
+						return err
+					}
+				}
+				for i, message := range n.Messages {
+					field := fmt.Sprintf("%smessages[%d].content", prefix, i)
+					if err := validateTemplateRefs(parsed, field, node.ID, message.Content); err != nil {
+						return err
+					}
+				}
+			case NodeTypeHTTP:
+				if n.HTTP != nil {
+					if err := validateHTTPTemplates(prefix, node.ID, n.HTTP, funcs); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateHTTPTemplates checks that the templated fields of an http node parse
+func validateHTTPTemplates(prefix, name string, config *HTTPConfig, funcs template.FuncMap) error {
+	if err := validateTemplate(prefix+"http.method", name+".method", config.Method, funcs); err != nil {
+		return err
+	}
+	if err := validateTemplate(prefix+"http.url", name+".url", config.URL, funcs); err != nil {
+		return err
+	}
+	for header, value := range config.Headers {
+		if err := validateTemplate(prefix+"http.headers."+header, name+".headers."+header, value, funcs); err != nil {
+			return err
+		}
+	}
+	return validateTemplate(prefix+"http.body", name+".body", config.Body, funcs)
+}
+
+// validateTemplateRefs parses text with the flow's parsed partials and checks the
+// templates it includes exist
+func validateTemplateRefs(partials *template.Template, field, name, text string) error {
+	tmpl, err := ParseWithPartials(partials, name, text)
+	if err != nil {
+		return ValidationError{Field: field, Message: err.Error()}
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree == nil {
+			continue
+		}
+		for _, ref := range templateRefs(t.Tree.Root) {
+			if tmpl.Lookup(ref) == nil {
+				return ValidationError{Field: field, Message: fmt.Sprintf("template %q is not a partial or defined in the same text", ref)}
+			}
+		}
+	}
+	return nil
+}
+
+// nestedNode returns the nested node of a map node, or nil
+func nestedNode(node *Node) *Node {
+	if node.Map == nil {
+		return nil
+	}
+	return node.Map.Node
+}
+
+// templateRefs returns the names of the templates invoked with {{template}} under a parse node
+func templateRefs(node parse.Node) []string {
+	var refs []string
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			refs = append(refs, templateRefs(child)...)
+		}
+	case *parse.TemplateNode:
+		refs = append(refs, n.Name)
+	case *parse.IfNode:
+		refs = append(refs, templateRefs(n.List)...)
+		refs = append(refs, templateRefs(n.ElseList)...)
+	case *parse.RangeNode:
+		refs = append(refs, templateRefs(n.List)...)
+		refs = append(refs, templateRefs(n.ElseList)...)
+	case *parse.WithNode:
+		refs = append(refs, templateRefs(n.List)...)
+		refs = append(refs, templateRefs(n.ElseList)...)
+	}
+	return refs
+}
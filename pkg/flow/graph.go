@@ -0,0 +1,108 @@
+package flow
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/broderick/prompt-flow/pkg/expr"
+)
+
+// SplitReference splits a "node_id.output_name" reference into its parts.
+// It returns false if the reference does not contain a dot.
+func SplitReference(ref string) (nodeID, outputName string, ok bool) {
+	parts := strings.SplitN(ref, ".", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// Dependencies returns the distinct IDs of the nodes this node must wait for:
+// nodes it reads inputs from and nodes referenced by its when condition.
+// Malformed references are ignored here and reported by Validate.
+func (n *Node) Dependencies() []string {
+	seen := make(map[string]bool)
+	ids := []string{}
+	add := func(ref string) {
+		if nodeID, _, ok := SplitReference(ref); ok && !seen[nodeID] {
+			seen[nodeID] = true
+			ids = append(ids, nodeID)
+		}
+	}
+
+	for _, input := range n.Inputs {
+		if input.From != "input" {
+			add(input.From)
+		}
+	}
+
+	if n.When != "" {
+		if cond, err := expr.Parse(n.When); err == nil {
+			for _, ref := range cond.References() {
+				add(ref)
+			}
+		}
+	}
+
+	return ids
+}
+
+// DependencyGraph maps each node ID to the IDs of the nodes it must wait for. On top
+// of each node's own Dependencies, the targets of a switch node depend on the switch
+// and an error handler node depends on the node it handles.
+func (f *Flow) DependencyGraph() map[string][]string {
+	graph := make(map[string][]string, len(f.Nodes))
+	for i := range f.Nodes {
+		graph[f.Nodes[i].ID] = f.Nodes[i].Dependencies()
+	}
+
+	for _, node := range f.Nodes {
+		handler := node.ErrorHandler()
+		if handler == "" || handler == node.ID {
+			continue
+		}
+		if deps, ok := graph[handler]; ok && !slices.Contains(deps, node.ID) {
+			graph[handler] = append(deps, node.ID)
+		}
+	}
+
+	for _, node := range f.Nodes {
+		if node.Switch == nil {
+			continue
+		}
+		for _, target := range node.Switch.Targets() {
+			if target == node.ID || slices.Contains(graph[target], node.ID) {
+				continue
+			}
+			graph[target] = append(graph[target], node.ID)
+		}
+	}
+
+	return graph
+}
+
+// Descendants returns the IDs of the given node and every node that depends on it,
+// directly or indirectly, according to graph
+func Descendants(graph map[string][]string, nodeID string) map[string]bool {
+	dependents := make(map[string][]string)
+	for id, deps := range graph {
+		for _, dep := range deps {
+			dependents[dep] = append(dependents[dep], id)
+		}
+	}
+
+	found := map[string]bool{nodeID: true}
+	queue := []string{nodeID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, dependent := range dependents[id] {
+			if !found[dependent] {
+				found[dependent] = true
+				queue = append(queue, dependent)
+			}
+		}
+	}
+
+	return found
+}
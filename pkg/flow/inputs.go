@@ -0,0 +1,177 @@
+package flow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// InputTypes lists the valid flow input types
+var InputTypes = []string{InputTypeString, InputTypeNumber, InputTypeBool, InputTypeList, InputTypeObject}
+
+// DeclaresInputs reports whether the flow has an inputs section. Flows without one
+// take whatever inputs their nodes read.
+func (f *Flow) DeclaresInputs() bool {
+	return f.Inputs != nil
+}
+
+// PrepareInputs checks the values given for a run against the flow's declared inputs
+// and returns the values the run should use: each converted to its input's type, with
+// defaults and zero values filled in for inputs that were not given. Values for
+// undeclared inputs are rejected. Flows that declare no inputs get values unchanged.
+func (f *Flow) PrepareInputs(values map[string]any) (map[string]any, error) {
+	if !f.DeclaresInputs() {
+		return values, nil
+	}
+
+	declared := make(map[string]bool, len(f.Inputs))
+	for _, input := range f.Inputs {
+		declared[input.Name] = true
+	}
+
+	var errs []error
+	for name := range values {
+		if !declared[name] {
+			errs = append(errs, fmt.Errorf("unknown input: %s", name))
+		}
+	}
+
+	prepared := make(map[string]any, len(f.Inputs))
+	for _, input := range f.Inputs {
+		val, ok := values[input.Name]
+		switch {
+		case ok:
+		case input.Default != nil:
+			val = input.Default
+		case input.Required:
+			errs = append(errs, fmt.Errorf("required input not provided: %s", input.Name))
+			continue
+		default:
+			prepared[input.Name] = zeroInput(input.TypeName())
+			continue
+		}
+
+		converted, err := convertInput(input.TypeName(), val)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("input %s: %w", input.Name, err))
+			continue
+		}
+		prepared[input.Name] = converted
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return prepared, nil
+}
+
+// requiredInputNames returns the names of the inputs a run of the flow must be given
+func (f *Flow) requiredInputNames() []string {
+	if !f.DeclaresInputs() {
+		return f.InputNames()
+	}
+
+	names := []string{}
+	for _, input := range f.Inputs {
+		if input.Required {
+			names = append(names, input.Name)
+		}
+	}
+	return names
+}
+
+// convertInput converts a value to the given input type. Strings, such as values
+// given on the command line, are parsed into the other types.
+func convertInput(typ string, val any) (any, error) {
+	switch typ {
+	case InputTypeString:
+		switch v := val.(type) {
+		case string:
+			return v, nil
+		case bool, int, int64, float64:
+			return fmt.Sprint(v), nil
+		}
+		return nil, fmt.Errorf("expected a string, got %T", val)
+
+	case InputTypeNumber:
+		switch v := val.(type) {
+		case int:
+			return float64(v), nil
+		case int64:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		case string:
+			n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, fmt.Errorf("expected a number, got %q", v)
+			}
+			return n, nil
+		}
+		return nil, fmt.Errorf("expected a number, got %T", val)
+
+	case InputTypeBool:
+		switch v := val.(type) {
+		case bool:
+			return v, nil
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return nil, fmt.Errorf("expected true or false, got %q", v)
+			}
+			return b, nil
+		}
+		return nil, fmt.Errorf("expected a bool, got %T", val)
+
+	case InputTypeList:
+		switch v := val.(type) {
+		case []any:
+			return v, nil
+		case []string:
+			items := make([]any, len(v))
+			for i, s := range v {
+				items[i] = s
+			}
+			return items, nil
+		case string:
+			var items []any
+			if err := json.Unmarshal([]byte(v), &items); err != nil || items == nil {
+				return nil, fmt.Errorf("expected a JSON array, got %q", v)
+			}
+			return items, nil
+		}
+		return nil, fmt.Errorf("expected a list, got %T", val)
+
+	case InputTypeObject:
+		switch v := val.(type) {
+		case map[string]any:
+			return v, nil
+		case string:
+			var obj map[string]any
+			if err := json.Unmarshal([]byte(v), &obj); err != nil || obj == nil {
+				return nil, fmt.Errorf("expected a JSON object, got %q", v)
+			}
+			return obj, nil
+		}
+		return nil, fmt.Errorf("expected an object, got %T", val)
+	}
+
+	return nil, fmt.Errorf("unknown input type: %s", typ)
+}
+
+// zeroInput returns the value of an optional input of the given type that was not given
+func zeroInput(typ string) any {
+	switch typ {
+	case InputTypeNumber:
+		return float64(0)
+	case InputTypeBool:
+		return false
+	case InputTypeList:
+		return []any{}
+	case InputTypeObject:
+		return map[string]any{}
+	}
+	return ""
+}
@@ -0,0 +1,268 @@
+package flow
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/broderick/prompt-flow/pkg/providers"
+)
+
+// NodeTypeValidator checks the fields that are specific to a node type
+type NodeTypeValidator func(node *Node) error
+
+var (
+	nodeTypesMu sync.RWMutex
+	nodeTypes   map[string]NodeTypeValidator
+)
+
+func init() {
+	// Registered in init because the map validator recursively validates its nested node
+	nodeTypes = map[string]NodeTypeValidator{
+		NodeTypeLLM:      validateLLMNode,
+		NodeTypeTemplate: validateTemplateNode,
+		NodeTypeHTTP:     validateHTTPNode,
+		NodeTypeSwitch:   validateSwitchNode,
+		NodeTypeMap:      validateMapNode,
+		NodeTypeSubflow:  validateSubflowNode,
+	}
+}
+
+// RegisterNodeType makes a node type known to Validate. The validator is called for
+// every node of that type and should check the fields the type requires. Registering
+// a type that already exists replaces its validator.
+//
+// Library users normally register node types through executor.RegisterNodeHandler,
+// which calls this for them.
+func RegisterNodeType(nodeType string, validate NodeTypeValidator) {
+	if nodeType == "" {
+		panic("flow: node type name is required")
+	}
+	if validate == nil {
+		validate = func(*Node) error { return nil }
+	}
+
+	nodeTypesMu.Lock()
+	defer nodeTypesMu.Unlock()
+	nodeTypes[nodeType] = validate
+}
+
+// NodeTypes returns the names of all known node types, sorted
+func NodeTypes() []string {
+	nodeTypesMu.RLock()
+	defer nodeTypesMu.RUnlock()
+
+	names := make([]string, 0, len(nodeTypes))
+	for name := range nodeTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupNodeType(nodeType string) (NodeTypeValidator, bool) {
+	nodeTypesMu.RLock()
+	defer nodeTypesMu.RUnlock()
+	validate, ok := nodeTypes[nodeType]
+	return validate, ok
+}
+
+func validateLLMNode(node *Node) error {
+	if len(node.Messages) > 0 {
+		if err := validateMessages(node); err != nil {
+			return err
+		}
+	} else {
+		if node.Prompt != "" && node.PromptFile != "" {
+			return ValidationError{Field: "prompt_file", Message: "prompt and prompt_file cannot both be set"}
+		}
+		if node.Prompt == "" && node.PromptFile == "" {
+			return ValidationError{Field: "prompt", Message: fmt.Sprintf("prompt, prompt_file or messages is required for %s nodes", NodeTypeLLM)}
+		}
+	}
+	for i, fallback := range node.Fallbacks {
+		if fallback.Provider == "" && fallback.Model == "" {
+			return ValidationError{
+				Field:   fmt.Sprintf("fallbacks[%d]", i),
+				Message: "a fallback must set a provider or a model",
+			}
+		}
+	}
+	return nil
+}
+
+// validateMessages checks the chat messages of an llm node
+func validateMessages(node *Node) error {
+	if node.Prompt != "" || node.PromptFile != "" {
+		return ValidationError{Field: "messages", Message: "messages cannot be combined with prompt or prompt_file"}
+	}
+
+	hasUser := false
+	for i, message := range node.Messages {
+		field := fmt.Sprintf("messages[%d]", i)
+		switch message.Role {
+		case providers.RoleSystem, providers.RoleAssistant:
+		case providers.RoleUser:
+			hasUser = true
+		default:
+			return ValidationError{
+				Field: field + ".role",
+				Message: fmt.Sprintf("unknown role: %q (expected '%s', '%s' or '%s')",
+					message.Role, providers.RoleSystem, providers.RoleUser, providers.RoleAssistant),
+			}
+		}
+		if message.Content == "" {
+			return ValidationError{Field: field + ".content", Message: "content is required"}
+		}
+	}
+	if !hasUser {
+		return ValidationError{Field: "messages", Message: "at least one user message is required"}
+	}
+
+	return nil
+}
+
+func validateTemplateNode(node *Node) error {
+	if node.Prompt != "" && node.PromptFile != "" {
+		return ValidationError{Field: "prompt_file", Message: "prompt and prompt_file cannot both be set"}
+	}
+	if node.Prompt == "" && node.PromptFile == "" {
+		return ValidationError{Field: "prompt", Message: fmt.Sprintf("prompt or prompt_file is required for %s nodes", NodeTypeTemplate)}
+	}
+	if len(node.Outputs) == 0 {
+		return ValidationError{Field: "outputs", Message: fmt.Sprintf("at least one output is required for %s nodes", NodeTypeTemplate)}
+	}
+	return nil
+}
+
+func validateHTTPNode(node *Node) error {
+	if node.HTTP == nil {
+		return ValidationError{Field: "http", Message: fmt.Sprintf("http block is required for %s nodes", NodeTypeHTTP)}
+	}
+	if node.HTTP.URL == "" {
+		return ValidationError{Field: "http.url", Message: "URL is required"}
+	}
+	if node.HTTP.Method != "" && !strings.Contains(node.HTTP.Method, "{{") {
+		switch strings.ToUpper(node.HTTP.Method) {
+		case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+			http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		default:
+			return ValidationError{Field: "http.method", Message: fmt.Sprintf("unsupported HTTP method: %s", node.HTTP.Method)}
+		}
+	}
+	if node.HTTP.Timeout < 0 {
+		return ValidationError{Field: "http.timeout", Message: "timeout cannot be negative"}
+	}
+	if node.HTTP.Retries < 0 {
+		return ValidationError{Field: "http.retries", Message: "retries cannot be negative"}
+	}
+	if node.HTTP.RetryDelay < 0 {
+		return ValidationError{Field: "http.retry_delay", Message: "retry delay cannot be negative"}
+	}
+
+	outputNames := make(map[string]bool)
+	for _, output := range node.Outputs {
+		outputNames[output.Name] = true
+	}
+	for name, source := range node.HTTP.Extract {
+		field := fmt.Sprintf("http.extract.%s", name)
+		if !outputNames[name] {
+			return ValidationError{Field: field, Message: fmt.Sprintf("output not declared: %s", name)}
+		}
+		if source != "status" && source != "body" && !strings.HasPrefix(source, "body.") {
+			return ValidationError{
+				Field:   field,
+				Message: fmt.Sprintf("invalid source: %s (expected 'status', 'body' or 'body.<path>')", source),
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateSwitchNode(node *Node) error {
+	if node.Switch == nil {
+		return ValidationError{Field: "switch", Message: fmt.Sprintf("switch block is required for %s nodes", NodeTypeSwitch)}
+	}
+	if len(node.Switch.Cases) == 0 && node.Switch.Default == "" {
+		return ValidationError{Field: "switch.cases", Message: "at least one case or a default is required"}
+	}
+	if len(node.Inputs) == 0 {
+		return ValidationError{Field: "inputs", Message: fmt.Sprintf("%s nodes need an input to switch on", NodeTypeSwitch)}
+	}
+	if node.Switch.Value != "" && !slices.ContainsFunc(node.Inputs, func(input Input) bool { return input.Name == node.Switch.Value }) {
+		return ValidationError{Field: "switch.value", Message: fmt.Sprintf("input not declared: %s", node.Switch.Value)}
+	}
+
+	caseValues := make(map[string]string)
+	for value, target := range node.Switch.Cases {
+		field := fmt.Sprintf("switch.cases.%s", value)
+		normalized := strings.ToLower(strings.TrimSpace(value))
+		if normalized == "" {
+			return ValidationError{Field: "switch.cases", Message: "case value cannot be empty"}
+		}
+		if other, ok := caseValues[normalized]; ok {
+			return ValidationError{Field: field, Message: fmt.Sprintf("case overlaps with case %s", other)}
+		}
+		caseValues[normalized] = value
+		if target == "" {
+			return ValidationError{Field: field, Message: "target node ID is required"}
+		}
+	}
+
+	return nil
+}
+
+func validateMapNode(node *Node) error {
+	if node.Map == nil {
+		return ValidationError{Field: "map", Message: fmt.Sprintf("map block is required for %s nodes", NodeTypeMap)}
+	}
+	if len(node.Inputs) == 0 {
+		return ValidationError{Field: "inputs", Message: fmt.Sprintf("%s nodes need a list input", NodeTypeMap)}
+	}
+	if node.Map.Items != "" && !slices.ContainsFunc(node.Inputs, func(input Input) bool { return input.Name == node.Map.Items }) {
+		return ValidationError{Field: "map.items", Message: fmt.Sprintf("input not declared: %s", node.Map.Items)}
+	}
+	if node.Map.MaxConcurrency < 0 {
+		return ValidationError{Field: "map.max_concurrency", Message: "max concurrency cannot be negative"}
+	}
+	if len(node.Outputs) == 0 {
+		return ValidationError{Field: "outputs", Message: fmt.Sprintf("at least one output is required for %s nodes", NodeTypeMap)}
+	}
+
+	itemName := node.Map.ItemInputName()
+	if itemName == MapIndexInput || slices.ContainsFunc(node.Inputs, func(input Input) bool { return input.Name == itemName }) {
+		return ValidationError{Field: "map.as", Message: fmt.Sprintf("item input name clashes with another input: %s", itemName)}
+	}
+
+	nested := node.Map.Node
+	if nested == nil {
+		return ValidationError{Field: "map.node", Message: "nested node is required"}
+	}
+	if len(nested.Inputs) > 0 {
+		return ValidationError{Field: "map.node.inputs", Message: "nested node receives the map node's inputs and the current item; it cannot declare inputs"}
+	}
+	if nested.When != "" {
+		return ValidationError{Field: "map.node.when", Message: "nested node cannot have a when condition"}
+	}
+	if nested.TypeName() == NodeTypeSwitch {
+		return ValidationError{Field: "map.node.type", Message: fmt.Sprintf("nested node cannot be a %s node", NodeTypeSwitch)}
+	}
+
+	item := node.Map.ItemNode(node)
+	if err := validateNode(&item, nil); err != nil {
+		return fmt.Errorf("map.node: %w", err)
+	}
+
+	return nil
+}
+
+func validateSubflowNode(node *Node) error {
+	if node.Subflow == nil || node.Subflow.Path == "" {
+		return ValidationError{Field: "subflow.path", Message: fmt.Sprintf("subflow path is required for %s nodes", NodeTypeSubflow)}
+	}
+	return nil
+}
@@ -20,7 +20,8 @@ func Parse(filePath string) (*Flow, error) {
 	return ParseBytes(data, filePath)
 }
 
-// ParseBytes parses flow definition from bytes, auto-detecting format
+// ParseBytes parses flow definition from bytes, auto-detecting format from the
+// filename, which is also recorded as the flow's FilePath
 func ParseBytes(data []byte, filename string) (*Flow, error) {
 	var flow Flow
 	ext := strings.ToLower(filepath.Ext(filename))
@@ -43,6 +44,7 @@ func ParseBytes(data []byte, filename string) (*Flow, error) {
 		}
 	}
 
+	flow.FilePath = filename
 	return &flow, nil
 }
 
@@ -0,0 +1,37 @@
+package flow
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// LoadRun reads an execution result saved with SaveRun
+func LoadRun(filePath string) (*ExecutionResult, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	var result ExecutionResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse run: %w", err)
+	}
+
+	return &result, nil
+}
+
+// SaveRun writes an execution result to a JSON file so that it can later be used to
+// re-run part of the flow
+func SaveRun(result *ExecutionResult, filePath string) error {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal run: %w", err)
+	}
+
+	if err := os.WriteFile(filePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package flow
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/broderick/prompt-flow/pkg/schema"
+)
+
+// LoadSchema returns the parsed schema of a node, or nil if it has none. A schema
+// given as a string is read from that file, relative to the flow file.
+func (f *Flow) LoadSchema(node *Node) (*schema.Schema, error) {
+	switch def := node.Schema.(type) {
+	case nil:
+		return nil, nil
+
+	case map[string]any:
+		return schema.Parse(def)
+
+	case string:
+		data, err := os.ReadFile(f.ResolvePath(def))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read schema: %w", err)
+		}
+		// YAML is a superset of JSON, so this reads either
+		var fileDef map[string]any
+		if err := yaml.Unmarshal(data, &fileDef); err != nil {
+			return nil, fmt.Errorf("failed to parse schema %s: %w", def, err)
+		}
+		s, err := schema.Parse(fileDef)
+		if err != nil {
+			return nil, fmt.Errorf("schema %s: %w", def, err)
+		}
+		return s, nil
+	}
+
+	return nil, fmt.Errorf("schema must be an object or a file path, got %T", node.Schema)
+}
+
+// validateSchemas checks that the schema of every node, including the nested nodes of
+// map nodes, can be loaded and is only set on llm nodes
+func validateSchemas(flow *Flow) error {
+	for _, node := range flow.Nodes {
+		nodes := []*Node{&node}
+		if node.Map != nil && node.Map.Node != nil {
+			nodes = append(nodes, node.Map.Node)
+		}
+
+		for _, n := range nodes {
+			prefix := fmt.Sprintf("node %s, ", node.ID)
+			if n != &node {
+				prefix += "map.node."
+			}
+
+			if n.Schema == nil {
+				if n.Repairs != 0 {
+					return ValidationError{Field: prefix + "repairs", Message: "repairs requires a schema"}
+				}
+				continue
+			}
+			if n.TypeName() != NodeTypeLLM {
+				return ValidationError{Field: prefix + "schema", Message: "schema is only supported on llm nodes"}
+			}
+			if n.Repairs < 0 {
+				return ValidationError{Field: prefix + "repairs", Message: "repairs cannot be negative"}
+			}
+			if _, err := flow.LoadSchema(n); err != nil {
+				return ValidationError{Field: prefix + "schema", Message: err.Error()}
+			}
+		}
+	}
+
+	return nil
+}
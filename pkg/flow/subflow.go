@@ -0,0 +1,157 @@
+package flow
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ResolvePath resolves a path referenced by the flow relative to the directory of
+// the flow file. Absolute paths are returned unchanged.
+func (f *Flow) ResolvePath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(f.FilePath), path)
+}
+
+// InputNames returns the names of the flow's inputs: the declared inputs in order,
+// or, for flows that do not declare them, the distinct inputs used by the flow's
+// nodes in order of first use
+func (f *Flow) InputNames() []string {
+	if f.DeclaresInputs() {
+		names := make([]string, len(f.Inputs))
+		for i, input := range f.Inputs {
+			names[i] = input.Name
+		}
+		return names
+	}
+
+	seen := make(map[string]bool)
+	names := []string{}
+	for _, node := range f.Nodes {
+		for _, input := range node.Inputs {
+			if input.From == "input" && !seen[input.Name] {
+				seen[input.Name] = true
+				names = append(names, input.Name)
+			}
+		}
+	}
+	return names
+}
+
+// OutputNames returns the names of the outputs the flow exposes with `to: output`
+func (f *Flow) OutputNames() []string {
+	names := []string{}
+	for _, node := range f.Nodes {
+		for _, output := range node.Outputs {
+			if output.To == "output" {
+				names = append(names, output.Name)
+			}
+		}
+	}
+	return names
+}
+
+// LoadSubflow parses the child flow referenced by a subflow node
+func (f *Flow) LoadSubflow(node *Node) (*Flow, error) {
+	child, err := Parse(f.ResolvePath(node.Subflow.Path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load subflow %s: %w", node.Subflow.Path, err)
+	}
+	return child, nil
+}
+
+// SubflowPath returns the absolute path of the child flow file of a subflow node
+func (f *Flow) SubflowPath(node *Node) (string, error) {
+	return filepath.Abs(f.ResolvePath(node.Subflow.Path))
+}
+
+// validateSubflows loads and validates the child flow of every subflow node, including
+// the nested nodes of map nodes, and checks that the node's inputs and outputs line up
+// with the child flow. stack holds the absolute paths of the flow files currently being
+// validated, outermost first, and is used to detect flows that include themselves.
+func validateSubflows(flow *Flow, stack []string, o *validateOptions) error {
+	for _, node := range flow.Nodes {
+		if node.Subflow != nil {
+			if err := validateSubflow(flow, &node, fmt.Sprintf("node %s, subflow", node.ID), false, stack, o); err != nil {
+				return err
+			}
+		}
+
+		// A nested node receives the map node's inputs plus the item and its index
+		// whether it uses them or not
+		if node.Map != nil && node.Map.Node != nil && node.Map.Node.Subflow != nil {
+			item := node.Map.ItemNode(&node)
+			if err := validateSubflow(flow, &item, fmt.Sprintf("node %s, map.node.subflow", node.ID), true, stack, o); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateSubflow validates the child flow of a subflow node against the node. When
+// implicitInputs is set, the node's inputs are given to it rather than chosen, so
+// only a child flow that declares its inputs must accept all of them.
+func validateSubflow(flow *Flow, node *Node, field string, implicitInputs bool, stack []string, o *validateOptions) error {
+	path, err := flow.SubflowPath(node)
+	if err != nil {
+		return ValidationError{Field: field, Message: fmt.Sprintf("invalid path %s: %v", node.Subflow.Path, err)}
+	}
+	for i, parent := range stack {
+		if parent == path {
+			chain := append(append([]string{}, stack[i:]...), path)
+			for j := range chain {
+				chain[j] = filepath.Base(chain[j])
+			}
+			return ValidationError{
+				Field:   field,
+				Message: fmt.Sprintf("recursive subflow inclusion: %s", strings.Join(chain, " -> ")),
+			}
+		}
+	}
+
+	// A child flow included several times is loaded and validated once
+	child, ok := o.subflows[path]
+	if !ok {
+		child, err = flow.LoadSubflow(node)
+		if err != nil {
+			return ValidationError{Field: field, Message: err.Error()}
+		}
+		if err := validate(child, append(stack, path), o); err != nil {
+			return fmt.Errorf("%s %s: %w", field, node.Subflow.Path, err)
+		}
+		o.subflows[path] = child
+	}
+
+	childInputs := make(map[string]bool)
+	for _, name := range child.InputNames() {
+		childInputs[name] = true
+	}
+	nodeInputs := make(map[string]bool)
+	for _, input := range node.Inputs {
+		if !childInputs[input.Name] && (child.DeclaresInputs() || !implicitInputs) {
+			return ValidationError{Field: field, Message: fmt.Sprintf("child flow has no input named %s", input.Name)}
+		}
+		nodeInputs[input.Name] = true
+	}
+	for _, name := range child.requiredInputNames() {
+		if !nodeInputs[name] {
+			return ValidationError{Field: field, Message: fmt.Sprintf("child flow input not provided: %s", name)}
+		}
+	}
+
+	childOutputs := make(map[string]bool)
+	for _, name := range child.OutputNames() {
+		childOutputs[name] = true
+	}
+	for _, output := range node.Outputs {
+		if !childOutputs[output.Name] {
+			return ValidationError{Field: field, Message: fmt.Sprintf("child flow has no output named %s", output.Name)}
+		}
+	}
+
+	return nil
+}
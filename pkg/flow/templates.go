@@ -0,0 +1,145 @@
+package flow
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TemplateFuncs returns the functions available to every template in a flow: node
+// prompts and the fields of http nodes. Arguments that are not strings are formatted
+// with fmt.Sprint where a string is expected.
+//
+//	json VALUE          VALUE encoded as JSON
+//	toYaml VALUE        VALUE encoded as YAML, without a trailing newline
+//	join SEP LIST       the items of LIST separated by SEP
+//	trim TEXT           TEXT without leading and trailing white space
+//	upper TEXT          TEXT in upper case
+//	lower TEXT          TEXT in lower case
+//	truncate N TEXT     the first N characters of TEXT
+//	indent N TEXT       TEXT with every line indented by N spaces
+//	default DEF VALUE   VALUE, or DEF if VALUE is empty (nil, false, 0, "" or an empty list or map)
+//	now [LAYOUT]        the current time, formatted with the Go time LAYOUT (default RFC 3339)
+//
+// The text/template built-ins, such as len, index and printf, are available too.
+//
+// Functions that take the value last can be used in pipelines, e.g.
+// {{.review | trim | truncate 500}} or {{.tags | join ", "}}.
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"json":     toJSON,
+		"toYaml":   toYAML,
+		"join":     join,
+		"trim":     func(v any) string { return strings.TrimSpace(toString(v)) },
+		"upper":    func(v any) string { return strings.ToUpper(toString(v)) },
+		"lower":    func(v any) string { return strings.ToLower(toString(v)) },
+		"truncate": truncate,
+		"indent":   indent,
+		"default":  defaultValue,
+		"now":      now,
+	}
+}
+
+// validateTemplate checks that text parses as a template using the built-in
+// functions and funcs. name is the template name used in parse errors.
+func validateTemplate(field, name, text string, funcs template.FuncMap) error {
+	if _, err := template.New(name).Funcs(TemplateFuncs()).Funcs(funcs).Parse(text); err != nil {
+		return ValidationError{Field: field, Message: err.Error()}
+	}
+	return nil
+}
+
+func toString(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+func toJSON(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func toYAML(v any) (string, error) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+func join(sep string, list any) (string, error) {
+	if list == nil {
+		return "", nil
+	}
+	if s, ok := list.(string); ok {
+		return s, nil
+	}
+
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return "", fmt.Errorf("join: expected a list, got %T", list)
+	}
+	items := make([]string, v.Len())
+	for i := range items {
+		items[i] = toString(v.Index(i).Interface())
+	}
+	return strings.Join(items, sep), nil
+}
+
+func truncate(n int, v any) string {
+	s := toString(v)
+	if n < 0 || utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+func indent(n int, v any) string {
+	pad := strings.Repeat(" ", n)
+	lines := strings.Split(toString(v), "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = pad + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func defaultValue(def, v any) any {
+	if v == nil {
+		return def
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array, reflect.String:
+		if rv.Len() == 0 {
+			return def
+		}
+	default:
+		if rv.IsZero() {
+			return def
+		}
+	}
+	return v
+}
+
+func now(layout ...string) (string, error) {
+	switch len(layout) {
+	case 0:
+		return time.Now().Format(time.RFC3339), nil
+	case 1:
+		return time.Now().Format(layout[0]), nil
+	}
+	return "", fmt.Errorf("now: expected at most one layout, got %d", len(layout))
+}
@@ -1,34 +1,268 @@
 package flow
 
-import "time"
+import (
+	"sort"
+	"time"
+
+	"github.com/broderick/prompt-flow/pkg/providers"
+)
 
 // Flow represents a complete prompt flow definition
 type Flow struct {
-	Version     string `yaml:"version" json:"version"`
+	Version     string      `yaml:"version" json:"version"`
+	Name        string      `yaml:"name" json:"name"`
+	Description string      `yaml:"description,omitempty" json:"description,omitempty"`
+	Config      Config      `yaml:"config,omitempty" json:"config,omitempty"`
+	Inputs      []FlowInput `yaml:"inputs,omitempty" json:"inputs,omitempty"`     // Declared flow inputs; when omitted, inputs are implied by the nodes
+	Partials    string      `yaml:"partials,omitempty" json:"partials,omitempty"` // Directory of templates that prompts can include by file name
+	Nodes       []Node      `yaml:"nodes" json:"nodes"`
+
+	// FilePath is the file the flow was loaded from. Relative paths in the flow, such
+	// as subflow files, are resolved against its directory.
+	FilePath string `yaml:"-" json:"-"`
+}
+
+// FlowInput declares an input of the flow. Values given for it are converted to its
+// type before the flow runs.
+type FlowInput struct {
 	Name        string `yaml:"name" json:"name"`
+	Type        string `yaml:"type,omitempty" json:"type,omitempty"` // One of the InputType constants, defaults to "string"
 	Description string `yaml:"description,omitempty" json:"description,omitempty"`
-	Config      Config `yaml:"config,omitempty" json:"config,omitempty"`
-	Nodes       []Node `yaml:"nodes" json:"nodes"`
+	Default     any    `yaml:"default,omitempty" json:"default,omitempty"`   // Value used when none is given
+	Required    bool   `yaml:"required,omitempty" json:"required,omitempty"` // A value must be given; optional inputs without a default take their type's zero value
+}
+
+// TypeName returns the input's type, falling back to the default "string" type when unset
+func (i *FlowInput) TypeName() string {
+	if i.Type == "" {
+		return InputTypeString
+	}
+	return i.Type
 }
 
+// Flow input types
+const (
+	InputTypeString = "string"
+	InputTypeNumber = "number"
+	InputTypeBool   = "bool"
+	InputTypeList   = "list"   // A JSON array when given as a string
+	InputTypeObject = "object" // A JSON object when given as a string
+)
+
 // Config holds flow-level configuration
 type Config struct {
 	DefaultProvider string            `yaml:"default_provider,omitempty" json:"default_provider,omitempty"`
 	DefaultModel    string            `yaml:"default_model,omitempty" json:"default_model,omitempty"`
 	Settings        map[string]string `yaml:"settings,omitempty" json:"settings,omitempty"`
+	MaxConcurrency  int               `yaml:"max_concurrency,omitempty" json:"max_concurrency,omitempty"` // Maximum nodes run at once (0 = unlimited); map items are limited separately
+	Retry           *RetryPolicy      `yaml:"retry,omitempty" json:"retry,omitempty"`                     // Default retry policy for provider calls
+	Timeout         Duration          `yaml:"timeout,omitempty" json:"timeout,omitempty"`                 // Maximum time for the whole flow (0 = no limit)
+	OnError         string            `yaml:"on_error,omitempty" json:"on_error,omitempty"`               // "fail" (default) or "continue" when a node fails
+	Budget          *Budget           `yaml:"budget,omitempty" json:"budget,omitempty"`                   // Spending limits for a run
+}
+
+// Budget limits what a single run may spend across all of its nodes, including map
+// items and subflows. The run stops before a node once a limit has been reached, and
+// before an llm call whose estimated cost would take it over max_cost. Zero fields are
+// not limited.
+type Budget struct {
+	MaxCost         float64 `yaml:"max_cost,omitempty" json:"max_cost,omitempty"`                   // Total cost in USD
+	MaxInputTokens  int     `yaml:"max_input_tokens,omitempty" json:"max_input_tokens,omitempty"`   // Total prompt tokens
+	MaxOutputTokens int     `yaml:"max_output_tokens,omitempty" json:"max_output_tokens,omitempty"` // Total completion tokens
+}
+
+// RetryPolicy controls how failed provider calls are retried. Delays grow
+// exponentially from Backoff by Multiplier, capped at MaxBackoff, and are then
+// randomised by up to ±Jitter of their length.
+type RetryPolicy struct {
+	MaxAttempts    int                    `yaml:"max_attempts,omitempty" json:"max_attempts,omitempty"`       // Total attempts including the first (0 or 1 = no retries)
+	Backoff        Duration               `yaml:"backoff,omitempty" json:"backoff,omitempty"`                 // Delay before the first retry, defaults to 1s
+	MaxBackoff     Duration               `yaml:"max_backoff,omitempty" json:"max_backoff,omitempty"`         // Longest delay between attempts, defaults to 30s
+	Multiplier     float64                `yaml:"multiplier,omitempty" json:"multiplier,omitempty"`           // Delay growth per attempt, defaults to 2
+	Jitter         float64                `yaml:"jitter,omitempty" json:"jitter,omitempty"`                   // Fraction of each delay to randomise, 0 to 1
+	RetryOn        []providers.ErrorClass `yaml:"retry_on,omitempty" json:"retry_on,omitempty"`               // Error classes to retry, defaults to rate_limit, server_error, timeout and network
+	AttemptTimeout Duration               `yaml:"attempt_timeout,omitempty" json:"attempt_timeout,omitempty"` // Maximum time for each attempt, timed out attempts are retried as timeouts
 }
 
 // Node represents a single node in the flow
 type Node struct {
-	ID       string         `yaml:"id" json:"id"`
+	ID         string         `yaml:"id" json:"id"`
+	Type       string         `yaml:"type,omitempty" json:"type,omitempty"` // Node type, defaults to "llm"
+	Provider   string         `yaml:"provider,omitempty" json:"provider,omitempty"`
+	Model      string         `yaml:"model,omitempty" json:"model,omitempty"`
+	Inputs     []Input        `yaml:"inputs" json:"inputs"`
+	Prompt     string         `yaml:"prompt,omitempty" json:"prompt,omitempty"`
+	PromptFile string         `yaml:"prompt_file,omitempty" json:"prompt_file,omitempty"` // File the prompt is read from, instead of prompt
+	Messages   []Message      `yaml:"messages,omitempty" json:"messages,omitempty"`       // Chat turns sent instead of a prompt
+	Outputs    []Output       `yaml:"outputs" json:"outputs"`
+	OutputMode string         `yaml:"output_mode,omitempty" json:"output_mode,omitempty"` // "text" (default) or "json"
+	When       string         `yaml:"when,omitempty" json:"when,omitempty"`               // Condition that must hold for the node to run
+	Settings   map[string]any `yaml:"settings,omitempty" json:"settings,omitempty"`
+	HTTP       *HTTPConfig    `yaml:"http,omitempty" json:"http,omitempty"`           // Request definition for http nodes
+	Switch     *SwitchConfig  `yaml:"switch,omitempty" json:"switch,omitempty"`       // Branch definition for switch nodes
+	Map        *MapConfig     `yaml:"map,omitempty" json:"map,omitempty"`             // Per-item node definition for map nodes
+	Subflow    *SubflowConfig `yaml:"subflow,omitempty" json:"subflow,omitempty"`     // Child flow reference for subflow nodes
+	Retry      *RetryPolicy   `yaml:"retry,omitempty" json:"retry,omitempty"`         // Retry policy for provider calls, overrides the flow default
+	Fallbacks  []Fallback     `yaml:"fallbacks,omitempty" json:"fallbacks,omitempty"` // Providers/models tried in order if the primary fails
+	Timeout    Duration       `yaml:"timeout,omitempty" json:"timeout,omitempty"`     // Maximum time for the node, including retries and fallbacks (0 = no limit)
+	OnError    string         `yaml:"on_error,omitempty" json:"on_error,omitempty"`   // "fail", "continue" or the ID of a node to run if the node fails
+	Schema     any            `yaml:"schema,omitempty" json:"schema,omitempty"`       // JSON Schema the response must match, inline or the path of a JSON or YAML file
+	Repairs    int            `yaml:"repairs,omitempty" json:"repairs,omitempty"`     // Times to re-prompt with the mismatches when a response does not match the schema
+
+	// ResolvedPrompt is the contents of PromptFile, filled in by the server so the
+	// web UI can show it. It is never read from a flow file, and the executor reads
+	// the prompt file itself with Flow.LoadPrompt.
+	ResolvedPrompt string `yaml:"-" json:"resolved_prompt,omitempty"`
+}
+
+// Message is one turn of a chat-style llm prompt. Its content is a template, rendered
+// like a prompt.
+type Message struct {
+	Role    string `yaml:"role" json:"role"` // "system", "user" or "assistant"
+	Content string `yaml:"content" json:"content"`
+}
+
+// Fallback is an alternative provider and model for an llm node. Fields left empty
+// take the node's own values.
+type Fallback struct {
 	Provider string         `yaml:"provider,omitempty" json:"provider,omitempty"`
 	Model    string         `yaml:"model,omitempty" json:"model,omitempty"`
-	Inputs   []Input        `yaml:"inputs" json:"inputs"`
-	Prompt   string         `yaml:"prompt,omitempty" json:"prompt,omitempty"`
-	Outputs  []Output       `yaml:"outputs" json:"outputs"`
 	Settings map[string]any `yaml:"settings,omitempty" json:"settings,omitempty"`
 }
 
+// Built-in node types
+const (
+	NodeTypeLLM      = "llm"      // Renders the prompt and sends it to an LLM provider (default)
+	NodeTypeTemplate = "template" // Renders the prompt and uses the result as output without calling a model
+	NodeTypeHTTP     = "http"     // Sends an HTTP request and maps the response onto outputs
+	NodeTypeSwitch   = "switch"   // Runs one of several downstream branches depending on an input value
+	NodeTypeMap      = "map"      // Runs a nested node once for every item of a list input
+	NodeTypeSubflow  = "subflow"  // Runs another flow file as a single step
+)
+
+// TypeName returns the node's type, falling back to the default "llm" type when unset
+func (n *Node) TypeName() string {
+	if n.Type == "" {
+		return NodeTypeLLM
+	}
+	return n.Type
+}
+
+// ErrorHandler returns the ID of the node that handles this node's failures, or ""
+// when on_error is unset or names an error policy
+func (n *Node) ErrorHandler() string {
+	switch n.OnError {
+	case "", OnErrorFail, OnErrorContinue:
+		return ""
+	}
+	return n.OnError
+}
+
+// Output modes for a node. In text mode the whole response is written to the
+// first output. In JSON mode the response is parsed as a JSON object (optionally
+// inside a fenced code block) and each output is filled from the top-level field
+// of the same name.
+const (
+	OutputModeText = "text"
+	OutputModeJSON = "json"
+)
+
+// Error policies for on_error. Any other value names an error handler node.
+const (
+	OnErrorFail     = "fail"     // Stop the run at the first failure
+	OnErrorContinue = "continue" // Keep running nodes that do not depend on the failed node
+)
+
+// HTTPConfig describes the request made by an http node. Method, URL, header values
+// and body are Go templates rendered with the node's inputs.
+type HTTPConfig struct {
+	Method     string            `yaml:"method,omitempty" json:"method,omitempty"` // Defaults to GET
+	URL        string            `yaml:"url" json:"url"`
+	Headers    map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	Body       string            `yaml:"body,omitempty" json:"body,omitempty"`
+	Timeout    Duration          `yaml:"timeout,omitempty" json:"timeout,omitempty"`         // Per-attempt timeout (0 = none)
+	Retries    int               `yaml:"retries,omitempty" json:"retries,omitempty"`         // Extra attempts after a network error, 429 or 5xx
+	RetryDelay Duration          `yaml:"retry_delay,omitempty" json:"retry_delay,omitempty"` // Wait between attempts
+	// Extract maps output names to parts of the response: "status" for the status
+	// code, "body" for the raw body, or "body.<path>" for a value inside a JSON body
+	// (e.g. "body.order.items[0].sku"). When empty, the body goes to the first output.
+	Extract map[string]string `yaml:"extract,omitempty" json:"extract,omitempty"`
+}
+
+// SwitchConfig describes the branches of a switch node. Each case maps a value to
+// the ID of the node that starts the branch to run when the switch value matches it.
+// Values match ignoring surrounding whitespace and letter case.
+type SwitchConfig struct {
+	Value   string            `yaml:"value,omitempty" json:"value,omitempty"`     // Input to switch on, defaults to the node's first input
+	Cases   map[string]string `yaml:"cases" json:"cases"`                         // Case value -> node ID
+	Default string            `yaml:"default,omitempty" json:"default,omitempty"` // Node ID to run when no case matches
+}
+
+// Targets returns the distinct IDs of all nodes the switch can route to, sorted
+func (s *SwitchConfig) Targets() []string {
+	seen := make(map[string]bool)
+	targets := []string{}
+	for _, target := range s.Cases {
+		if !seen[target] {
+			seen[target] = true
+			targets = append(targets, target)
+		}
+	}
+	if s.Default != "" && !seen[s.Default] {
+		targets = append(targets, s.Default)
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// MapConfig describes the nested node a map node runs for each item of a list. The
+// nested node declares no inputs of its own: it receives all of the map node's inputs,
+// the current item under the name given by As, and the item's position as "index".
+type MapConfig struct {
+	Items          string `yaml:"items,omitempty" json:"items,omitempty"`                     // Input holding the list, defaults to the node's first input
+	As             string `yaml:"as,omitempty" json:"as,omitempty"`                           // Input name of the current item, defaults to "item"
+	MaxConcurrency int    `yaml:"max_concurrency,omitempty" json:"max_concurrency,omitempty"` // Items processed at once (0 = flow limit), on top of the flow's other running nodes
+	Node           *Node  `yaml:"node" json:"node"`                                           // Node run once per item
+}
+
+// MapIndexInput is the input name under which a map node's nested node receives the item index
+const MapIndexInput = "index"
+
+// ItemInputName returns the input name of the current item
+func (m *MapConfig) ItemInputName() string {
+	if m.As == "" {
+		return "item"
+	}
+	return m.As
+}
+
+// ItemNode returns the nested node as it is run for each item of the given map
+// node, with its ID defaulted and its implicit inputs filled in
+func (m *MapConfig) ItemNode(parent *Node) Node {
+	item := *m.Node
+	if item.ID == "" {
+		item.ID = parent.ID + ".item"
+	}
+
+	item.Inputs = make([]Input, 0, len(parent.Inputs)+2)
+	for _, input := range parent.Inputs {
+		item.Inputs = append(item.Inputs, Input{Name: input.Name, From: "input"})
+	}
+	item.Inputs = append(item.Inputs,
+		Input{Name: m.ItemInputName(), From: "input"},
+		Input{Name: MapIndexInput, From: "input"},
+	)
+
+	return item
+}
+
+// SubflowConfig references the child flow run by a subflow node. The node's inputs
+// are passed to the child flow's inputs of the same name, and the node's outputs are
+// read from the child flow's outputs of the same name.
+type SubflowConfig struct {
+	Path string `yaml:"path" json:"path"` // Flow file, relative to the parent flow file
+}
+
 // Input represents an input to a node
 type Input struct {
 	Name string `yaml:"name" json:"name"`
@@ -37,8 +271,9 @@ type Input struct {
 
 // Output represents an output from a node
 type Output struct {
-	Name string `yaml:"name" json:"name"`
-	To   string `yaml:"to,omitempty" json:"to,omitempty"` // "output" for flow output, or empty
+	Name    string `yaml:"name" json:"name"`
+	To      string `yaml:"to,omitempty" json:"to,omitempty"`           // "output" for flow output, or empty
+	Default any    `yaml:"default,omitempty" json:"default,omitempty"` // Value used when the node is skipped
 }
 
 // ExecutionResult represents the result of executing a flow
@@ -46,25 +281,69 @@ type ExecutionResult struct {
 	FlowName    string         `json:"flow_name"`
 	Success     bool           `json:"success"`
 	Error       string         `json:"error,omitempty"`
+	Inputs      map[string]any `json:"inputs,omitempty"` // The flow inputs the run was given
 	Outputs     map[string]any `json:"outputs"`
 	NodeResults []NodeResult   `json:"node_results"`
+	Errors      []NodeError    `json:"errors,omitempty"` // Every unhandled node failure, in execution order
+	Metrics     NodeMetrics    `json:"metrics"`          // Totals across the nodes run, excluding reused results
 	StartTime   time.Time      `json:"start_time"`
 	EndTime     time.Time      `json:"end_time"`
 	Duration    time.Duration  `json:"duration"`
 }
 
+// NodeError records the failure of a single node
+type NodeError struct {
+	NodeID string `json:"node_id"`
+	Error  string `json:"error"`
+}
+
 // NodeResult represents the result of executing a single node
 type NodeResult struct {
-	NodeID    string         `json:"node_id"`
-	Success   bool           `json:"success"`
-	Error     string         `json:"error,omitempty"`
-	Outputs   map[string]any `json:"outputs"`
-	Metrics   NodeMetrics    `json:"metrics"`
-	StartTime time.Time      `json:"start_time"`
-	EndTime   time.Time      `json:"end_time"`
-	Duration  time.Duration  `json:"duration"`
+	NodeID     string           `json:"node_id"`
+	Status     NodeStatus       `json:"status"`
+	Success    bool             `json:"success"`
+	Error      string           `json:"error,omitempty"`
+	SkipReason string           `json:"skip_reason,omitempty"` // Why the node was skipped
+	Branch     string           `json:"branch,omitempty"`      // For switch nodes, the ID of the node routed to
+	Items      []NodeResult     `json:"items,omitempty"`       // For map nodes, the result of each item in order
+	Subflow    *ExecutionResult `json:"subflow,omitempty"`     // For subflow nodes, the result of the child flow
+	HandledBy  string           `json:"handled_by,omitempty"`  // For failed nodes, the error handler node that was run
+	Reused     bool             `json:"reused,omitempty"`      // Copied from a previous run rather than executed
+	Cached     bool             `json:"cached,omitempty"`      // For llm nodes, answered from the response cache at no cost
+	Attempts   []Attempt        `json:"attempts,omitempty"`    // Every provider call made for the node, in order
+	Violations []string         `json:"violations,omitempty"`  // For nodes with a schema, how the last response failed to match it
+	Throttled  time.Duration    `json:"throttled,omitempty"`   // Time spent waiting for provider rate limits
+	Provider   string           `json:"provider,omitempty"`    // For llm nodes, the provider that answered
+	Model      string           `json:"model,omitempty"`       // For llm nodes, the model that answered
+	Outputs    map[string]any   `json:"outputs"`
+	RawOutput  string           `json:"raw_output,omitempty"` // Unparsed response text
+	Metrics    NodeMetrics      `json:"metrics"`
+	StartTime  time.Time        `json:"start_time"`
+	EndTime    time.Time        `json:"end_time"`
+	Duration   time.Duration    `json:"duration"`
+}
+
+// Attempt records a single provider call made while executing a node
+type Attempt struct {
+	Number     int                  `json:"number"` // 1 for the first attempt
+	Provider   string               `json:"provider"`
+	Model      string               `json:"model"`
+	Error      string               `json:"error,omitempty"`
+	ErrorClass providers.ErrorClass `json:"error_class,omitempty"`
+	StartTime  time.Time            `json:"start_time"`
+	Latency    time.Duration        `json:"latency"`
 }
 
+// NodeStatus describes how a node's execution ended
+type NodeStatus string
+
+// Node statuses
+const (
+	NodeStatusSucceeded NodeStatus = "succeeded"
+	NodeStatusFailed    NodeStatus = "failed"
+	NodeStatusSkipped   NodeStatus = "skipped" // The node's when condition was false, or all of its upstream nodes were skipped
+)
+
 // NodeMetrics contains performance metrics for a node execution
 type NodeMetrics struct {
 	InputTokens  int     `json:"input_tokens,omitempty"`
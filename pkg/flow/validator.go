@@ -2,7 +2,13 @@ package flow
 
 import (
 	"fmt"
+	"path/filepath"
+	"slices"
 	"strings"
+	"text/template"
+
+	"github.com/broderick/prompt-flow/pkg/expr"
+	"github.com/broderick/prompt-flow/pkg/providers"
 )
 
 // ValidationError represents a validation error
@@ -15,8 +21,56 @@ func (e ValidationError) Error() string {
 	return fmt.Sprintf("%s: %s", e.Field, e.Message)
 }
 
-// Validate checks if a flow definition is valid
-func Validate(flow *Flow) error {
+// ValidateOption configures Validate
+type ValidateOption func(*validateOptions)
+
+type validateOptions struct {
+	funcs    template.FuncMap
+	subflows map[string]*Flow // absolute path -> validated child flow
+}
+
+// WithFuncs makes template functions beyond TemplateFuncs known to Validate, so that
+// templates using them validate. Pass the same functions given to the executor with
+// executor.WithTemplateFuncs.
+func WithFuncs(funcs template.FuncMap) ValidateOption {
+	return func(o *validateOptions) {
+		if o.funcs == nil {
+			o.funcs = make(template.FuncMap, len(funcs))
+		}
+		for name, fn := range funcs {
+			o.funcs[name] = fn
+		}
+	}
+}
+
+// WithSubflows adds every child flow loaded and validated by Validate to subflows,
+// keyed by the absolute path of its file, so that a run can reuse them instead of
+// loading them again. Children of child flows are included.
+func WithSubflows(subflows map[string]*Flow) ValidateOption {
+	return func(o *validateOptions) {
+		o.subflows = subflows
+	}
+}
+
+// Validate checks if a flow definition is valid. Subflow files referenced by the
+// flow are loaded and validated too, once each, with the same options.
+func Validate(flow *Flow, opts ...ValidateOption) error {
+	o := validateOptions{subflows: make(map[string]*Flow)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var stack []string
+	if flow.FilePath != "" {
+		if path, err := filepath.Abs(flow.FilePath); err == nil {
+			stack = append(stack, path)
+		}
+	}
+	return validate(flow, stack, &o)
+}
+
+// validate validates a flow that is being included by the flow files in stack
+func validate(flow *Flow, stack []string, o *validateOptions) error {
 	if flow.Name == "" {
 		return ValidationError{Field: "name", Message: "flow name is required"}
 	}
@@ -25,6 +79,30 @@ func Validate(flow *Flow) error {
 		return ValidationError{Field: "version", Message: "flow version is required"}
 	}
 
+	if flow.Config.MaxConcurrency < 0 {
+		return ValidationError{Field: "config.max_concurrency", Message: "max concurrency cannot be negative"}
+	}
+
+	if flow.Config.Timeout < 0 {
+		return ValidationError{Field: "config.timeout", Message: "timeout cannot be negative"}
+	}
+
+	if err := validateOnError("config.on_error", flow.Config.OnError); err != nil {
+		return err
+	}
+
+	if err := validateRetryPolicy("config.retry", flow.Config.Retry); err != nil {
+		return err
+	}
+
+	if err := validateBudget("config.budget", flow.Config.Budget); err != nil {
+		return err
+	}
+
+	if err := validateInputs(flow); err != nil {
+		return err
+	}
+
 	if len(flow.Nodes) == 0 {
 		return ValidationError{Field: "nodes", Message: "at least one node is required"}
 	}
@@ -53,6 +131,16 @@ func Validate(flow *Flow) error {
 		}
 	}
 
+	// Check switch targets before cycles so that bad targets get a clear error
+	if err := validateSwitches(flow, nodeIDs); err != nil {
+		return err
+	}
+
+	// Error handler edges take part in cycle detection, so check them first too
+	if err := validateErrorHandlers(flow, nodeIDs); err != nil {
+		return err
+	}
+
 	// Check for cycles in the DAG
 	if err := checkCycles(flow); err != nil {
 		return err
@@ -63,12 +151,57 @@ func Validate(flow *Flow) error {
 		return err
 	}
 
+	// Check that the partials and node templates parse and only include known templates
+	if err := validateTemplates(flow, o.funcs); err != nil {
+		return err
+	}
+
+	// Check that node schemas exist and parse
+	if err := validateSchemas(flow); err != nil {
+		return err
+	}
+
+	// Validate child flows last, once the parent itself is known to be sound
+	if err := validateSubflows(flow, stack, o); err != nil {
+		return err
+	}
+
 	return nil
 }
 
 func validateNode(node *Node, existingIDs map[string]bool) error {
-	if node.Prompt == "" {
-		return ValidationError{Field: "prompt", Message: "prompt is required"}
+	validateType, ok := lookupNodeType(node.TypeName())
+	if !ok {
+		return ValidationError{
+			Field:   "type",
+			Message: fmt.Sprintf("unknown node type: %s", node.TypeName()),
+		}
+	}
+	if len(node.Messages) > 0 && node.TypeName() != NodeTypeLLM {
+		return ValidationError{Field: "messages", Message: fmt.Sprintf("messages are only supported on %s nodes", NodeTypeLLM)}
+	}
+	if len(node.Fallbacks) > 0 && node.TypeName() != NodeTypeLLM {
+		return ValidationError{Field: "fallbacks", Message: fmt.Sprintf("fallbacks are only supported on %s nodes", NodeTypeLLM)}
+	}
+	if err := validateType(node); err != nil {
+		return err
+	}
+
+	if node.Timeout < 0 {
+		return ValidationError{Field: "timeout", Message: "timeout cannot be negative"}
+	}
+
+	if err := validateRetryPolicy("retry", node.Retry); err != nil {
+		return err
+	}
+
+	switch node.OutputMode {
+	case "", OutputModeText, OutputModeJSON:
+	default:
+		return ValidationError{
+			Field:   "output_mode",
+			Message: fmt.Sprintf("unknown output mode: %s (expected '%s' or '%s')", node.OutputMode, OutputModeText, OutputModeJSON),
+		}
 	}
 
 	// Validate outputs
@@ -116,6 +249,153 @@ func validateNode(node *Node, existingIDs map[string]bool) error {
 	return nil
 }
 
+func validateOnError(field, policy string) error {
+	switch policy {
+	case "", OnErrorFail, OnErrorContinue:
+		return nil
+	}
+	return ValidationError{
+		Field:   field,
+		Message: fmt.Sprintf("unknown error policy: %s (expected '%s' or '%s')", policy, OnErrorFail, OnErrorContinue),
+	}
+}
+
+// validateErrorHandlers checks that every node's on_error is an error policy or the ID
+// of another node, and that each error handler handles a single node
+func validateErrorHandlers(flow *Flow, nodeIDs map[string]bool) error {
+	handles := make(map[string]string) // handler ID -> ID of the node it handles
+	for _, node := range flow.Nodes {
+		handler := node.ErrorHandler()
+		if handler == "" {
+			continue
+		}
+
+		field := fmt.Sprintf("node %s, on_error", node.ID)
+		if !nodeIDs[handler] {
+			return ValidationError{
+				Field:   field,
+				Message: fmt.Sprintf("unknown error policy or node: %s (expected '%s', '%s' or a node ID)", handler, OnErrorFail, OnErrorContinue),
+			}
+		}
+		if handler == node.ID {
+			return ValidationError{Field: field, Message: "node cannot handle its own errors"}
+		}
+		if other, ok := handles[handler]; ok {
+			return ValidationError{
+				Field:   field,
+				Message: fmt.Sprintf("node %s already handles the errors of node %s", handler, other),
+			}
+		}
+		handles[handler] = node.ID
+	}
+
+	// A handler runs because the node failed, so the node has no outputs to give it;
+	// the handler receives the node's inputs and the error instead
+	for _, node := range flow.Nodes {
+		handled, ok := handles[node.ID]
+		if !ok {
+			continue
+		}
+		for _, input := range node.Inputs {
+			if nodeID, _, ok := SplitReference(input.From); ok && nodeID == handled {
+				return ValidationError{
+					Field:   fmt.Sprintf("node %s, input %s", node.ID, input.Name),
+					Message: fmt.Sprintf("error handler cannot take inputs from the node it handles: %s; it receives that node's inputs and the error", input.From),
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateRetryPolicy(field string, policy *RetryPolicy) error {
+	if policy == nil {
+		return nil
+	}
+
+	if policy.MaxAttempts < 0 {
+		return ValidationError{Field: field + ".max_attempts", Message: "max attempts cannot be negative"}
+	}
+	if policy.Backoff < 0 {
+		return ValidationError{Field: field + ".backoff", Message: "backoff cannot be negative"}
+	}
+	if policy.MaxBackoff < 0 {
+		return ValidationError{Field: field + ".max_backoff", Message: "max backoff cannot be negative"}
+	}
+	if policy.Multiplier != 0 && policy.Multiplier < 1 {
+		return ValidationError{Field: field + ".multiplier", Message: "multiplier must be at least 1"}
+	}
+	if policy.Jitter < 0 || policy.Jitter > 1 {
+		return ValidationError{Field: field + ".jitter", Message: "jitter must be between 0 and 1"}
+	}
+	if policy.AttemptTimeout < 0 {
+		return ValidationError{Field: field + ".attempt_timeout", Message: "attempt timeout cannot be negative"}
+	}
+	for i, class := range policy.RetryOn {
+		if !slices.Contains(providers.ErrorClasses, class) {
+			return ValidationError{
+				Field:   fmt.Sprintf("%s.retry_on[%d]", field, i),
+				Message: fmt.Sprintf("unknown error class: %s (expected one of %v)", class, providers.ErrorClasses),
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateBudget(field string, budget *Budget) error {
+	if budget == nil {
+		return nil
+	}
+
+	if budget.MaxCost < 0 {
+		return ValidationError{Field: field + ".max_cost", Message: "max cost cannot be negative"}
+	}
+	if budget.MaxInputTokens < 0 {
+		return ValidationError{Field: field + ".max_input_tokens", Message: "max input tokens cannot be negative"}
+	}
+	if budget.MaxOutputTokens < 0 {
+		return ValidationError{Field: field + ".max_output_tokens", Message: "max output tokens cannot be negative"}
+	}
+
+	return nil
+}
+
+// validateInputs checks the flow's input declarations, including that each default
+// has the input's type
+func validateInputs(flow *Flow) error {
+	names := make(map[string]bool)
+	for i, input := range flow.Inputs {
+		if input.Name == "" {
+			return ValidationError{Field: fmt.Sprintf("inputs[%d].name", i), Message: "input name is required"}
+		}
+		field := fmt.Sprintf("input %s", input.Name)
+		if names[input.Name] {
+			return ValidationError{Field: field, Message: fmt.Sprintf("duplicate input name: %s", input.Name)}
+		}
+		names[input.Name] = true
+
+		if !slices.Contains(InputTypes, input.TypeName()) {
+			return ValidationError{
+				Field:   field + ", type",
+				Message: fmt.Sprintf("unknown input type: %s (expected one of %v)", input.Type, InputTypes),
+			}
+		}
+		if input.Default == nil {
+			continue
+		}
+		if input.Required {
+			return ValidationError{Field: field, Message: "required input cannot have a default"}
+		}
+		if _, err := convertInput(input.TypeName(), input.Default); err != nil {
+			return ValidationError{Field: field + ", default", Message: err.Error()}
+		}
+	}
+
+	return nil
+}
+
 func validateReferences(flow *Flow) error {
 	// Build a map of available outputs
 	availableOutputs := make(map[string]map[string]bool) // nodeID -> outputName -> true
@@ -127,11 +407,22 @@ func validateReferences(flow *Flow) error {
 		}
 	}
 
+	declaredInputs := make(map[string]bool)
+	for _, input := range flow.Inputs {
+		declaredInputs[input.Name] = true
+	}
+
 	// Check all input references
 	for _, node := range flow.Nodes {
 		for _, input := range node.Inputs {
 			if input.From == "input" {
-				// This is a flow input, always valid
+				// Flow inputs must be declared when the flow declares its inputs
+				if flow.DeclaresInputs() && !declaredInputs[input.Name] {
+					return ValidationError{
+						Field:   fmt.Sprintf("node %s, input %s", node.ID, input.Name),
+						Message: fmt.Sprintf("flow input is not declared: %s", input.Name),
+					}
+				}
 				continue
 			}
 
@@ -162,25 +453,67 @@ func validateReferences(flow *Flow) error {
 				}
 			}
 		}
+
+		// Check references in the when condition. Bare names refer to node or flow
+		// inputs, and must name one when the flow declares its inputs; dotted names
+		// must refer to an output of another node.
+		if node.When != "" {
+			cond, err := expr.Parse(node.When)
+			if err != nil {
+				return ValidationError{
+					Field:   fmt.Sprintf("node %s, when", node.ID),
+					Message: fmt.Sprintf("invalid condition: %v", err),
+				}
+			}
+			for _, ref := range cond.References() {
+				nodeID, outputName, ok := SplitReference(ref)
+				if !ok {
+					if flow.DeclaresInputs() && !declaredInputs[ref] && !hasInput(&node, ref) {
+						return ValidationError{
+							Field:   fmt.Sprintf("node %s, when", node.ID),
+							Message: fmt.Sprintf("unknown reference: %s (not an input of the node or a declared flow input)", ref),
+						}
+					}
+					continue
+				}
+				if nodeID == node.ID {
+					return ValidationError{
+						Field:   fmt.Sprintf("node %s, when", node.ID),
+						Message: fmt.Sprintf("condition cannot reference the node's own output: %s", ref),
+					}
+				}
+				if _, exists := availableOutputs[nodeID]; !exists {
+					return ValidationError{
+						Field:   fmt.Sprintf("node %s, when", node.ID),
+						Message: fmt.Sprintf("referenced node does not exist: %s", nodeID),
+					}
+				}
+				if !availableOutputs[nodeID][outputName] {
+					return ValidationError{
+						Field:   fmt.Sprintf("node %s, when", node.ID),
+						Message: fmt.Sprintf("referenced output does not exist: %s.%s", nodeID, outputName),
+					}
+				}
+			}
+		}
 	}
 
 	return nil
 }
 
-func checkCycles(flow *Flow) error {
-	// Build adjacency list
-	graph := make(map[string][]string)
-	for _, node := range flow.Nodes {
-		graph[node.ID] = []string{}
-		for _, input := range node.Inputs {
-			if input.From != "input" {
-				parts := strings.SplitN(input.From, ".", 2)
-				if len(parts) == 2 {
-					graph[node.ID] = append(graph[node.ID], parts[0])
-				}
-			}
+// hasInput reports whether the node has an input with the given name
+func hasInput(node *Node, name string) bool {
+	for _, input := range node.Inputs {
+		if input.Name == name {
+			return true
 		}
 	}
+	return false
+}
+
+func checkCycles(flow *Flow) error {
+	// Build adjacency list
+	graph := flow.DependencyGraph()
 
 	// DFS to detect cycles
 	visited := make(map[string]bool)
@@ -218,3 +551,45 @@ func checkCycles(flow *Flow) error {
 
 	return nil
 }
+
+// validateSwitches checks that every switch target exists and that branches are
+// independent: a branch cannot start inside another branch of the same switch,
+// otherwise taking one branch would both run and skip the same nodes.
+func validateSwitches(flow *Flow, nodeIDs map[string]bool) error {
+	graph := flow.DependencyGraph()
+	for _, node := range flow.Nodes {
+		if node.Switch == nil {
+			continue
+		}
+
+		targets := node.Switch.Targets()
+		for _, target := range targets {
+			if target == node.ID {
+				return ValidationError{
+					Field:   fmt.Sprintf("node %s, switch", node.ID),
+					Message: "switch cannot route to itself",
+				}
+			}
+			if !nodeIDs[target] {
+				return ValidationError{
+					Field:   fmt.Sprintf("node %s, switch", node.ID),
+					Message: fmt.Sprintf("target node does not exist: %s", target),
+				}
+			}
+		}
+
+		for _, target := range targets {
+			descendants := Descendants(graph, target)
+			for _, other := range targets {
+				if other != target && descendants[other] {
+					return ValidationError{
+						Field:   fmt.Sprintf("node %s, switch", node.ID),
+						Message: fmt.Sprintf("branch %s depends on branch %s; branches must be independent and only merge after their targets", other, target),
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -3,6 +3,7 @@ package providers
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/liushuangls/go-anthropic/v2"
 )
@@ -36,23 +37,30 @@ func (p *AnthropicProvider) Complete(ctx context.Context, req CompletionRequest)
 
 	// Get settings with defaults
 	temperature := float32(0.7)
-	maxTokens := 1000
+	maxTokens := req.MaxTokens()
 
 	if temp, ok := req.Settings["temperature"].(float64); ok {
 		temperature = float32(temp)
 	}
-	if max, ok := req.Settings["max_tokens"].(int); ok {
-		maxTokens = max
-	}
 
-	// Build the request
-	messages := []anthropic.Message{
-		anthropic.NewUserTextMessage(req.Prompt),
+	// Build the request. System messages go in the separate system prompt.
+	var messages []anthropic.Message
+	var system []string
+	for _, m := range req.ChatMessages() {
+		switch m.Role {
+		case RoleSystem:
+			system = append(system, m.Content)
+		case RoleAssistant:
+			messages = append(messages, anthropic.NewAssistantTextMessage(m.Content))
+		default:
+			messages = append(messages, anthropic.NewUserTextMessage(m.Content))
+		}
 	}
 
 	chatReq := anthropic.MessagesRequest{
 		Model:       anthropic.Model(req.Model),
 		Messages:    messages,
+		System:      strings.Join(system, "\n\n"),
 		Temperature: &temperature,
 		MaxTokens:   maxTokens,
 	}
@@ -82,25 +90,11 @@ func (p *AnthropicProvider) Complete(ctx context.Context, req CompletionRequest)
 	}, nil
 }
 
-// estimateAnthropicCost calculates approximate cost based on model and token usage
+// estimateAnthropicCost calculates approximate cost based on model and token usage.
+// Models without known pricing are charged at Haiku prices.
 func estimateAnthropicCost(model string, inputTokens, outputTokens int) (float64, float64) {
-	// Pricing as of 2024 (per 1M tokens)
-	var inputCost, outputCost float64
-
-	switch model {
-	case "claude-3-opus-20240229":
-		inputCost = 15.0  // $15 per 1M tokens
-		outputCost = 75.0 // $75 per 1M tokens
-	case "claude-3-5-sonnet-20241022", "claude-3-5-sonnet-20240620":
-		inputCost = 3.0   // $3 per 1M tokens
-		outputCost = 15.0 // $15 per 1M tokens
-	case "claude-3-sonnet-20240229":
-		inputCost = 3.0   // $3 per 1M tokens
-		outputCost = 15.0 // $15 per 1M tokens
-	case "claude-3-haiku-20240307":
-		inputCost = 0.25  // $0.25 per 1M tokens
-		outputCost = 1.25 // $1.25 per 1M tokens
-	default:
+	inputCost, outputCost, ok := anthropicPricing(model)
+	if !ok {
 		// Default to Haiku pricing
 		inputCost = 0.25
 		outputCost = 1.25
@@ -111,3 +105,20 @@ func estimateAnthropicCost(model string, inputTokens, outputTokens int) (float64
 
 	return inputCostUSD, outputCostUSD
 }
+
+// anthropicPricing returns the USD price per 1M input and output tokens of a model.
+// ok is false for models without known pricing.
+func anthropicPricing(model string) (inputCost, outputCost float64, ok bool) {
+	// Pricing as of 2024 (per 1M tokens)
+	switch model {
+	case "claude-3-opus-20240229":
+		return 15.0, 75.0, true // $15 and $75 per 1M tokens
+	case "claude-3-5-sonnet-20241022", "claude-3-5-sonnet-20240620":
+		return 3.0, 15.0, true // $3 and $15 per 1M tokens
+	case "claude-3-sonnet-20240229":
+		return 3.0, 15.0, true // $3 and $15 per 1M tokens
+	case "claude-3-haiku-20240307":
+		return 0.25, 1.25, true // $0.25 and $1.25 per 1M tokens
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,170 @@
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Cache stores provider responses on disk so that identical requests are answered
+// without calling the provider again. Entries are keyed by a hash of the provider name,
+// model, prompt and settings, and written atomically, so a cache directory can be
+// shared by concurrent calls and processes.
+type Cache struct {
+	dir string
+	ttl time.Duration
+	now func() time.Time // clock for entry ages, replaced in tests
+}
+
+// NewCache creates a cache stored in dir. Entries older than ttl are ignored; a ttl of
+// zero keeps entries forever.
+func NewCache(dir string, ttl time.Duration) *Cache {
+	return &Cache{dir: dir, ttl: ttl, now: time.Now}
+}
+
+// DefaultCacheDir returns the directory used for the cache when none is configured,
+// inside the user's cache directory
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find user cache directory: %w", err)
+	}
+	return filepath.Join(dir, "prompt-flow", "responses"), nil
+}
+
+// Wrap returns a provider that answers from the cache when it can and otherwise calls
+// p, caching successful responses
+func (c *Cache) Wrap(p Provider) Provider {
+	return &cachedProvider{provider: p, cache: c}
+}
+
+// cacheEntry is the on-disk form of a cached response
+type cacheEntry struct {
+	CreatedAt    time.Time `json:"created_at"`
+	Content      string    `json:"content"`
+	InputTokens  int       `json:"input_tokens"`
+	OutputTokens int       `json:"output_tokens"`
+	Model        string    `json:"model"`
+}
+
+// requestKey returns a hash identifying a request to the named provider
+func requestKey(provider string, req CompletionRequest) (string, error) {
+	// Map keys are marshalled in sorted order, so equal settings hash equally
+	data, err := json.Marshal(struct {
+		Provider string         `json:"provider"`
+		Model    string         `json:"model"`
+		Prompt   string         `json:"prompt"`
+		Messages []Message      `json:"messages,omitempty"`
+		Settings map[string]any `json:"settings"`
+	}{provider, req.Model, req.Prompt, req.Messages, req.Settings})
+	if err != nil {
+		return "", fmt.Errorf("failed to hash request: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+// get returns the cached response for key, if there is one that has not expired
+func (c *Cache) get(key string) (*CompletionResponse, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	if c.ttl > 0 && c.now().Sub(entry.CreatedAt) > c.ttl {
+		return nil, false
+	}
+
+	return &CompletionResponse{
+		Content:      entry.Content,
+		InputTokens:  entry.InputTokens,
+		OutputTokens: entry.OutputTokens,
+		Model:        entry.Model,
+		Cached:       true,
+	}, true
+}
+
+// put stores resp under key. The entry is written to a temporary file and renamed into
+// place so that readers never see a partly written entry.
+func (c *Cache) put(key string, resp *CompletionResponse) error {
+	data, err := json.Marshal(cacheEntry{
+		CreatedAt:    c.now(),
+		Content:      resp.Content,
+		InputTokens:  resp.InputTokens,
+		OutputTokens: resp.OutputTokens,
+		Model:        resp.Model,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache entry: %w", err)
+	}
+
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create cache entry: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+
+	return nil
+}
+
+// cachedProvider answers requests from a Cache before falling back to its provider
+type cachedProvider struct {
+	provider Provider
+	cache    *Cache
+}
+
+func (p *cachedProvider) Name() string {
+	return p.provider.Name()
+}
+
+// Complete returns the cached response for the request if there is one, with zero
+// cost. Otherwise it calls the wrapped provider and caches a successful response.
+// Failing to write the cache does not fail the call.
+func (p *cachedProvider) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	key, err := requestKey(p.provider.Name(), req)
+	if err != nil {
+		return p.provider.Complete(ctx, req)
+	}
+
+	if resp, ok := p.cache.get(key); ok {
+		return resp, nil
+	}
+
+	resp, err := p.provider.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	_ = p.cache.put(key, resp)
+
+	return resp, nil
+}
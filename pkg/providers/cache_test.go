@@ -0,0 +1,132 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRequestKey(t *testing.T) {
+	base := CompletionRequest{
+		Prompt:   "hello",
+		Model:    "m",
+		Settings: map[string]any{"temperature": 0.2, "max_tokens": 100},
+	}
+	baseKey, err := requestKey("p", base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Settings built in another order hash the same
+	reordered := base
+	reordered.Settings = map[string]any{"max_tokens": 100}
+	reordered.Settings["temperature"] = 0.2
+	if key, _ := requestKey("p", reordered); key != baseKey {
+		t.Errorf("requestKey changed when settings were built in another order")
+	}
+
+	tests := []struct {
+		name     string
+		provider string
+		req      CompletionRequest
+	}{
+		{name: "provider", provider: "q", req: base},
+		{name: "model", provider: "p", req: CompletionRequest{Prompt: "hello", Model: "n", Settings: base.Settings}},
+		{name: "prompt", provider: "p", req: CompletionRequest{Prompt: "hello!", Model: "m", Settings: base.Settings}},
+		{name: "settings", provider: "p", req: CompletionRequest{Prompt: "hello", Model: "m", Settings: map[string]any{"temperature": 0.3, "max_tokens": 100}}},
+		{name: "messages", provider: "p", req: CompletionRequest{Prompt: "hello", Model: "m", Settings: base.Settings, Messages: []Message{{Role: RoleUser, Content: "hello"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := requestKey(tt.provider, tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if key == baseKey {
+				t.Errorf("requestKey did not change with the %s", tt.name)
+			}
+		})
+	}
+}
+
+func TestCache(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	cache := NewCache(t.TempDir(), time.Hour)
+	cache.now = clock.Now
+
+	provider := &countingProvider{}
+	p := cache.Wrap(provider)
+	req := CompletionRequest{Prompt: "hello", Model: "m"}
+
+	resp, err := p.Complete(context.Background(), req)
+	if err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	if resp.Cached || resp.InputCost == 0 {
+		t.Errorf("first call cached = %v, cost %v, want an uncached, costed response", resp.Cached, resp.InputCost)
+	}
+
+	clock.advance(59 * time.Minute)
+	resp, err = p.Complete(context.Background(), req)
+	if err != nil {
+		t.Fatalf("second call: %v", err)
+	}
+	if !resp.Cached || provider.calls != 1 {
+		t.Fatalf("second call cached = %v after %d provider calls, want it served from the cache", resp.Cached, provider.calls)
+	}
+	if resp.Content != "ok" || resp.InputTokens != 10 || resp.OutputTokens != 5 {
+		t.Errorf("cached response = %+v, want the first call's content and tokens", resp)
+	}
+	if resp.InputCost != 0 || resp.OutputCost != 0 {
+		t.Errorf("cached response cost %v + %v, want no cost", resp.InputCost, resp.OutputCost)
+	}
+
+	// Once the entry is older than the TTL the provider is called again
+	clock.advance(2 * time.Minute)
+	resp, err = p.Complete(context.Background(), req)
+	if err != nil {
+		t.Fatalf("call after the TTL: %v", err)
+	}
+	if resp.Cached || provider.calls != 2 {
+		t.Errorf("call after the TTL cached = %v after %d provider calls, want the provider called again", resp.Cached, provider.calls)
+	}
+}
+
+func TestCacheWithoutTTL(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	cache := NewCache(t.TempDir(), 0)
+	cache.now = clock.Now
+
+	provider := &countingProvider{}
+	p := cache.Wrap(provider)
+	req := CompletionRequest{Prompt: "hello", Model: "m"}
+
+	if _, err := p.Complete(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	clock.advance(365 * 24 * time.Hour)
+	resp, err := p.Complete(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Cached || provider.calls != 1 {
+		t.Errorf("cached = %v after %d provider calls, want entries kept forever", resp.Cached, provider.calls)
+	}
+}
+
+func TestCacheSkipsFailedCalls(t *testing.T) {
+	provider := &countingProvider{err: errors.New("boom")}
+	p := NewCache(t.TempDir(), 0).Wrap(provider)
+	req := CompletionRequest{Prompt: "hello", Model: "m"}
+
+	for range 2 {
+		if _, err := p.Complete(context.Background(), req); err == nil {
+			t.Fatal("Complete succeeded, want the provider's error")
+		}
+	}
+	if provider.calls != 2 {
+		t.Errorf("provider called %d times, want failures not to be cached", provider.calls)
+	}
+}
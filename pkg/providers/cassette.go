@@ -0,0 +1,239 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// ErrNotRecorded is returned by a replaying provider for a request that is not in
+// its cassette
+var ErrNotRecorded = errors.New("request not recorded in cassette")
+
+// Cassette is a recording of provider calls, used to replay a run without calling
+// any providers, e.g. in tests without API keys
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a single recorded provider call
+type Interaction struct {
+	Provider string           `json:"provider"`
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is the recorded form of a CompletionRequest
+type RecordedRequest struct {
+	Model    string         `json:"model"`
+	Prompt   string         `json:"prompt"`
+	Messages []Message      `json:"messages,omitempty"`
+	Settings map[string]any `json:"settings,omitempty"`
+}
+
+// RecordedResponse is the recorded form of a CompletionResponse
+type RecordedResponse struct {
+	Content      string  `json:"content"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	InputCost    float64 `json:"input_cost"`
+	OutputCost   float64 `json:"output_cost"`
+	Model        string  `json:"model,omitempty"`
+}
+
+// LoadCassette reads a cassette file written by Cassette.Save
+func LoadCassette(filePath string) (*Cassette, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+
+	var cassette Cassette
+	if err := json.Unmarshal(data, &cassette); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette: %w", err)
+	}
+
+	return &cassette, nil
+}
+
+// Save writes the cassette to a JSON file
+func (c *Cassette) Save(filePath string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cassette: %w", err)
+	}
+
+	if err := os.WriteFile(filePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+
+	return nil
+}
+
+// Recorder records every successful call made through the providers it wraps. Use
+// it as registry middleware with Registry.Use(recorder.Wrap).
+type Recorder struct {
+	mu           sync.Mutex
+	interactions []Interaction
+}
+
+// NewRecorder creates an empty recorder
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Wrap returns a provider that calls p and records each successful call
+func (r *Recorder) Wrap(p Provider) Provider {
+	return &recordingProvider{provider: p, recorder: r}
+}
+
+// Cassette returns the calls recorded so far, in the order they completed
+func (r *Recorder) Cassette() *Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	interactions := make([]Interaction, len(r.interactions))
+	copy(interactions, r.interactions)
+	return &Cassette{Interactions: interactions}
+}
+
+func (r *Recorder) record(interaction Interaction) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interactions = append(r.interactions, interaction)
+}
+
+// recordingProvider records the calls made to its provider
+type recordingProvider struct {
+	provider Provider
+	recorder *Recorder
+}
+
+func (p *recordingProvider) Name() string {
+	return p.provider.Name()
+}
+
+func (p *recordingProvider) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	resp, err := p.provider.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	p.recorder.record(Interaction{
+		Provider: p.provider.Name(),
+		Request: RecordedRequest{
+			Model:    req.Model,
+			Prompt:   req.Prompt,
+			Messages: req.Messages,
+			Settings: req.Settings,
+		},
+		Response: RecordedResponse{
+			Content:      resp.Content,
+			InputTokens:  resp.InputTokens,
+			OutputTokens: resp.OutputTokens,
+			InputCost:    resp.InputCost,
+			OutputCost:   resp.OutputCost,
+			Model:        resp.Model,
+		},
+	})
+
+	return resp, nil
+}
+
+// Replayer answers provider calls from a cassette instead of calling the providers.
+// Use it as registry middleware with Registry.Use(replayer.Wrap). Requests match a
+// recording when their provider, model, prompt and settings are equal. A request
+// recorded several times gets the recorded responses in order, and then the last one
+// again.
+type Replayer struct {
+	mu        sync.Mutex
+	responses map[string][]RecordedResponse // request key -> recorded responses
+	served    map[string]int                // request key -> responses served so far
+}
+
+// NewReplayer creates a replayer serving the responses in cassette
+func NewReplayer(cassette *Cassette) (*Replayer, error) {
+	r := &Replayer{
+		responses: make(map[string][]RecordedResponse),
+		served:    make(map[string]int),
+	}
+
+	for i, interaction := range cassette.Interactions {
+		key, err := requestKey(interaction.Provider, CompletionRequest{
+			Prompt:   interaction.Request.Prompt,
+			Messages: interaction.Request.Messages,
+			Model:    interaction.Request.Model,
+			Settings: interaction.Request.Settings,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("interaction %d: %w", i, err)
+		}
+		r.responses[key] = append(r.responses[key], interaction.Response)
+	}
+
+	return r, nil
+}
+
+// Wrap returns a provider with p's name that serves recorded responses and never
+// calls p
+func (r *Replayer) Wrap(p Provider) Provider {
+	return &replayingProvider{name: p.Name(), replayer: r}
+}
+
+func (r *Replayer) next(key string) (RecordedResponse, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	responses := r.responses[key]
+	if len(responses) == 0 {
+		return RecordedResponse{}, false
+	}
+
+	i := min(r.served[key], len(responses)-1)
+	r.served[key]++
+	return responses[i], true
+}
+
+// replayingProvider serves responses from a Replayer
+type replayingProvider struct {
+	name     string
+	replayer *Replayer
+}
+
+func (p *replayingProvider) Name() string {
+	return p.name
+}
+
+func (p *replayingProvider) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	key, err := requestKey(p.name, req)
+	if err != nil {
+		return nil, err
+	}
+
+	recorded, ok := p.replayer.next(key)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s model %s, prompt %q", ErrNotRecorded, p.name, req.Model, truncatePrompt(req.Text()))
+	}
+
+	return &CompletionResponse{
+		Content:      recorded.Content,
+		InputTokens:  recorded.InputTokens,
+		OutputTokens: recorded.OutputTokens,
+		InputCost:    recorded.InputCost,
+		OutputCost:   recorded.OutputCost,
+		Model:        recorded.Model,
+	}, nil
+}
+
+// truncatePrompt shortens a prompt to 80 characters for use in an error message
+func truncatePrompt(prompt string) string {
+	const maxLength = 80
+	runes := []rune(prompt)
+	if len(runes) <= maxLength {
+		return prompt
+	}
+	return string(runes[:maxLength]) + "..."
+}
@@ -0,0 +1,89 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCassetteRoundTrip(t *testing.T) {
+	recorded := &countingProvider{}
+	recorder := NewRecorder()
+	p := recorder.Wrap(recorded)
+
+	requests := []CompletionRequest{
+		{Prompt: "hello", Model: "m"},
+		{Model: "m", Settings: map[string]any{"max_tokens": 100, "temperature": 0.2}, Messages: []Message{
+			{Role: RoleSystem, Content: "be brief"},
+			{Role: RoleUser, Content: "hello"},
+		}},
+	}
+	for _, req := range requests {
+		if _, err := p.Complete(context.Background(), req); err != nil {
+			t.Fatalf("recording %+v: %v", req, err)
+		}
+	}
+
+	// Failed calls are not recorded
+	recorded.err = errors.New("boom")
+	if _, err := p.Complete(context.Background(), CompletionRequest{Prompt: "fails", Model: "m"}); err == nil {
+		t.Fatal("failing call succeeded while recording")
+	}
+
+	path := filepath.Join(t.TempDir(), "run.cassette.json")
+	if err := recorder.Cassette().Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		t.Fatalf("LoadCassette: %v", err)
+	}
+	if len(cassette.Interactions) != 2 {
+		t.Fatalf("cassette has %d interactions, want the 2 successful calls", len(cassette.Interactions))
+	}
+
+	replayer, err := NewReplayer(cassette)
+	if err != nil {
+		t.Fatalf("NewReplayer: %v", err)
+	}
+	replayed := &countingProvider{}
+	p = replayer.Wrap(replayed)
+
+	for _, req := range requests {
+		resp, err := p.Complete(context.Background(), req)
+		if err != nil {
+			t.Fatalf("replaying %+v: %v", req, err)
+		}
+		want := CompletionResponse{Content: "ok", InputTokens: 10, OutputTokens: 5, InputCost: 0.01, OutputCost: 0.02}
+		if *resp != want {
+			t.Errorf("replayed response = %+v, want %+v", *resp, want)
+		}
+	}
+	if replayed.calls != 0 {
+		t.Errorf("provider called %d times while replaying, want none", replayed.calls)
+	}
+
+	_, err = p.Complete(context.Background(), CompletionRequest{Prompt: "fails", Model: "m"})
+	if !errors.Is(err, ErrNotRecorded) {
+		t.Fatalf("replaying an unrecorded call: err = %v, want ErrNotRecorded", err)
+	}
+	if want := `counting model m, prompt "fails"`; !strings.Contains(err.Error(), want) {
+		t.Errorf("error %q does not name the provider, model and prompt as %q", err, want)
+	}
+}
+
+func TestReplayerNotRecordedTruncatesPrompt(t *testing.T) {
+	replayer, err := NewReplayer(&Cassette{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := replayer.Wrap(&countingProvider{})
+
+	prompt := strings.Repeat("é", 100)
+	_, err = p.Complete(context.Background(), CompletionRequest{Prompt: prompt, Model: "m"})
+	if want := `prompt "` + strings.Repeat("é", 80) + `..."`; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("error = %v, want the prompt cut to 80 characters", err)
+	}
+}
@@ -0,0 +1,116 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/liushuangls/go-anthropic/v2"
+	"github.com/sashabaranov/go-openai"
+)
+
+// ErrorClass groups provider errors so that retry policies can decide which ones are
+// worth retrying
+type ErrorClass string
+
+// Error classes returned by ClassifyError
+const (
+	ErrorClassRateLimit   ErrorClass = "rate_limit"   // HTTP 429 or a provider rate limit error
+	ErrorClassServerError ErrorClass = "server_error" // HTTP 5xx or a provider overloaded/internal error
+	ErrorClassTimeout     ErrorClass = "timeout"      // The request timed out
+	ErrorClassNetwork     ErrorClass = "network"      // The provider could not be reached
+	ErrorClassClientError ErrorClass = "client_error" // Any other HTTP 4xx, e.g. a bad request or API key
+	ErrorClassUnknown     ErrorClass = "unknown"      // Anything else
+)
+
+// ErrorClasses lists every error class, in the order they are documented
+var ErrorClasses = []ErrorClass{
+	ErrorClassRateLimit,
+	ErrorClassServerError,
+	ErrorClassTimeout,
+	ErrorClassNetwork,
+	ErrorClassClientError,
+	ErrorClassUnknown,
+}
+
+// StatusCoder is implemented by errors that carry an HTTP status code. Custom
+// providers can return errors implementing it to have them classified by status.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// ClassifyError returns the class of an error returned by Provider.Complete
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ""
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassTimeout
+	}
+
+	var anthropicErr *anthropic.APIError
+	if errors.As(err, &anthropicErr) {
+		switch {
+		case anthropicErr.IsRateLimitErr():
+			return ErrorClassRateLimit
+		case anthropicErr.IsOverloadedErr(), anthropicErr.IsApiErr():
+			return ErrorClassServerError
+		default:
+			return ErrorClassClientError
+		}
+	}
+
+	if status := statusCode(err); status > 0 {
+		return classifyStatus(status)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ErrorClassTimeout
+		}
+		return ErrorClassNetwork
+	}
+
+	return ErrorClassUnknown
+}
+
+// statusCode extracts the HTTP status code from known provider errors, or returns 0
+func statusCode(err error) int {
+	var openaiAPIErr *openai.APIError
+	if errors.As(err, &openaiAPIErr) {
+		return openaiAPIErr.HTTPStatusCode
+	}
+
+	var openaiReqErr *openai.RequestError
+	if errors.As(err, &openaiReqErr) {
+		return openaiReqErr.HTTPStatusCode
+	}
+
+	var anthropicReqErr *anthropic.RequestError
+	if errors.As(err, &anthropicReqErr) {
+		return anthropicReqErr.StatusCode
+	}
+
+	var coder StatusCoder
+	if errors.As(err, &coder) {
+		return coder.StatusCode()
+	}
+
+	return 0
+}
+
+func classifyStatus(status int) ErrorClass {
+	switch {
+	case status == 429:
+		return ErrorClassRateLimit
+	case status == 408:
+		return ErrorClassTimeout
+	case status >= 500:
+		return ErrorClassServerError
+	case status >= 400:
+		return ErrorClassClientError
+	}
+	return ErrorClassUnknown
+}
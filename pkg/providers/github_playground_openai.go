@@ -38,23 +38,22 @@ func (p *GithubPlaygroundOpenAIProvider) Complete(ctx context.Context, req Compl
 	}
 
 	// Build the request
-	messages := []openai.ChatCompletionMessage{
-		{
-			Role:    openai.ChatMessageRoleUser,
-			Content: req.Prompt,
-		},
+	var messages []openai.ChatCompletionMessage
+	for _, m := range req.ChatMessages() {
+		// The roles have the same names in the OpenAI API
+		messages = append(messages, openai.ChatCompletionMessage{
+			Role:    m.Role,
+			Content: m.Content,
+		})
 	}
 
 	// Get settings with defaults
 	temperature := 0.7
-	maxTokens := 1000
+	maxTokens := req.MaxTokens()
 
 	if temp, ok := req.Settings["temperature"].(float64); ok {
 		temperature = temp
 	}
-	if max, ok := req.Settings["max_tokens"].(int); ok {
-		maxTokens = max
-	}
 
 	chatReq := openai.ChatCompletionRequest{
 		Model:       req.Model,
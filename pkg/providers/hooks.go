@@ -0,0 +1,90 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// CallHooks are run for the requests a Registry's providers actually send, and not for
+// those answered by middleware such as a Cache or Replayer. Nil hooks are skipped.
+type CallHooks struct {
+	// Before is called before a request waits for the provider's rate limit. An
+	// error stops the request and is returned by Complete as it is.
+	Before func(req CompletionRequest) error
+
+	// Throttled is called with the time a request spent waiting for the provider's
+	// rate limit
+	Throttled func(wait time.Duration)
+
+	// After is called with the response to a request the provider answered
+	After func(resp *CompletionResponse)
+}
+
+type callHooksKey struct{}
+
+// WithCallHooks returns a context whose provider requests run hooks. Hooks set on an
+// outer context are replaced.
+func WithCallHooks(ctx context.Context, hooks CallHooks) context.Context {
+	return context.WithValue(ctx, callHooksKey{}, hooks)
+}
+
+// callHooksFrom returns the hooks carried by ctx
+func callHooksFrom(ctx context.Context) CallHooks {
+	hooks, _ := ctx.Value(callHooksKey{}).(CallHooks)
+	return hooks
+}
+
+// EstimateTokens roughly estimates the number of tokens in text, at four characters
+// per token
+func EstimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// hookedProvider is the innermost wrapper of every provider in a Registry. It runs
+// around requests that reach the provider itself, so middleware answering a request
+// skips it: the request runs no hooks and is not counted against the rate limit.
+type hookedProvider struct {
+	provider Provider
+	registry *Registry
+}
+
+func (p *hookedProvider) Name() string {
+	return p.provider.Name()
+}
+
+func (p *hookedProvider) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	hooks := callHooksFrom(ctx)
+	if hooks.Before != nil {
+		if err := hooks.Before(req); err != nil {
+			return nil, err
+		}
+	}
+
+	done := func(int) {}
+	if limiter, ok := p.registry.RateLimiter(p.provider.Name()); ok {
+		// Until the provider answers, count the prompt and the most the completion may use
+		tokens := EstimateTokens(req.Text()) + req.MaxTokens()
+
+		start := time.Now()
+		var err error
+		done, err = limiter.Wait(ctx, tokens)
+		if hooks.Throttled != nil {
+			hooks.Throttled(time.Since(start))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("waiting for %s rate limit: %w", p.provider.Name(), err)
+		}
+	}
+
+	resp, err := p.provider.Complete(ctx, req)
+	if resp != nil {
+		done(resp.InputTokens + resp.OutputTokens)
+	} else {
+		done(EstimateTokens(req.Text()))
+	}
+	if err == nil && hooks.After != nil {
+		hooks.After(resp)
+	}
+	return resp, err
+}
@@ -0,0 +1,35 @@
+package providers
+
+import "strings"
+
+// EstimateCost estimates the cost in USD of a call to one of the built-in providers
+// with the given token counts. ok is false when the provider or model has no known
+// pricing.
+func EstimateCost(provider, model string, inputTokens, outputTokens int) (inputCost, outputCost float64, ok bool) {
+	var estimate func(model string, inputTokens, outputTokens int) (float64, float64)
+	switch provider {
+	case "openai":
+		estimate = estimateOpenAICost
+	case "github_playground_openai":
+		model = strings.TrimPrefix(model, "openai/")
+		estimate = estimateOpenAICost
+	case "anthropic":
+		// The provider charges unknown models at Haiku prices, which would make an
+		// estimate for them look known
+		if _, _, ok := anthropicPricing(model); !ok {
+			return 0, 0, false
+		}
+		estimate = estimateAnthropicCost
+	default:
+		return 0, 0, false
+	}
+
+	// Models without pricing are estimated at zero cost
+	perMillionIn, perMillionOut := estimate(model, 1_000_000, 1_000_000)
+	if perMillionIn == 0 && perMillionOut == 0 {
+		return 0, 0, false
+	}
+
+	inputCost, outputCost = estimate(model, inputTokens, outputTokens)
+	return inputCost, outputCost, true
+}
@@ -3,6 +3,7 @@ package providers
 import (
 	"context"
 	"os"
+	"strings"
 )
 
 // Provider is the interface that all LLM providers must implement
@@ -17,10 +18,58 @@ type Provider interface {
 // CompletionRequest represents a request to an LLM
 type CompletionRequest struct {
 	Prompt   string         // The prompt text
+	Messages []Message      // Chat turns, sent instead of Prompt when set
 	Model    string         // Model identifier
 	Settings map[string]any // Provider-specific settings
 }
 
+// Message is one turn of a chat-style request
+type Message struct {
+	Role    string `json:"role"` // One of the Role constants
+	Content string `json:"content"`
+}
+
+// Message roles
+const (
+	RoleSystem    = "system"    // Instructions for the model
+	RoleUser      = "user"      // Text from the user
+	RoleAssistant = "assistant" // A reply from the model, e.g. in few-shot examples
+)
+
+// ChatMessages returns the request's messages, or its prompt as a single user message
+// when it has none
+func (r CompletionRequest) ChatMessages() []Message {
+	if len(r.Messages) > 0 {
+		return r.Messages
+	}
+	return []Message{{Role: RoleUser, Content: r.Prompt}}
+}
+
+// Text returns the request's prompt, or its messages as "role: content" paragraphs,
+// for display and token estimates
+func (r CompletionRequest) Text() string {
+	if len(r.Messages) == 0 {
+		return r.Prompt
+	}
+	parts := make([]string, len(r.Messages))
+	for i, m := range r.Messages {
+		parts[i] = m.Role + ": " + m.Content
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// DefaultMaxTokens is the completion limit the built-in providers send when a request
+// does not set max_tokens
+const DefaultMaxTokens = 1000
+
+// MaxTokens returns the request's max_tokens setting, or DefaultMaxTokens when it has none
+func (r CompletionRequest) MaxTokens() int {
+	if maxTokens, ok := r.Settings["max_tokens"].(int); ok {
+		return maxTokens
+	}
+	return DefaultMaxTokens
+}
+
 // CompletionResponse represents a response from an LLM
 type CompletionResponse struct {
 	Content      string  // The generated text
@@ -29,17 +78,24 @@ type CompletionResponse struct {
 	InputCost    float64 // Cost in USD for the input tokens
 	OutputCost   float64 // Cost in USD for the output tokens
 	Model        string  // Model that was used
+	Cached       bool    // Answered from a Cache rather than by the provider, at no cost
 }
 
+// Middleware wraps a provider to add behaviour around its calls, such as caching
+type Middleware func(Provider) Provider
+
 // Registry holds all available providers
 type Registry struct {
-	providers map[string]Provider
+	providers  map[string]Provider
+	middleware []Middleware
+	limiters   map[string]*RateLimiter
 }
 
 // NewRegistry creates a new provider registry
 func NewRegistry() *Registry {
 	return &Registry{
 		providers: make(map[string]Provider),
+		limiters:  make(map[string]*RateLimiter),
 	}
 }
 
@@ -54,8 +110,42 @@ func (r *Registry) WithDefaultProviders() *Registry {
 	return r
 }
 
+// Use wraps every provider in the registry, including providers registered later, with
+// the given middleware. Middleware added later wraps the providers outermost.
+func (r *Registry) Use(middleware Middleware) *Registry {
+	r.middleware = append(r.middleware, middleware)
+	for name, provider := range r.providers {
+		r.providers[name] = middleware(provider)
+	}
+	return r
+}
+
+// WithCache answers requests to every provider in the registry, including providers
+// registered later, from the given cache where possible
+func (r *Registry) WithCache(cache *Cache) *Registry {
+	return r.Use(cache.Wrap)
+}
+
+// WithRateLimit limits the requests sent to the named provider. Each request waits on
+// the provider's limiter, see RateLimiter, before it is sent. Requests answered by
+// middleware, such as a Cache or Replayer, are not limited.
+func (r *Registry) WithRateLimit(name string, limit RateLimit) *Registry {
+	r.limiters[name] = NewRateLimiter(limit)
+	return r
+}
+
+// RateLimiter returns the rate limiter of the named provider, if it has one
+func (r *Registry) RateLimiter(name string) (*RateLimiter, bool) {
+	l, ok := r.limiters[name]
+	return l, ok
+}
+
 // Register adds a provider to the registry
 func (r *Registry) Register(provider Provider) {
+	provider = &hookedProvider{provider: provider, registry: r}
+	for _, middleware := range r.middleware {
+		provider = middleware(provider)
+	}
 	r.providers[provider.Name()] = provider
 }
 
@@ -0,0 +1,122 @@
+package providers
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimit limits the requests sent to a provider, to stay within the provider's own
+// limits instead of being rejected by them. Zero fields are not limited.
+type RateLimit struct {
+	RequestsPerMinute int // Requests started in any one minute
+	TokensPerMinute   int // Prompt and completion tokens of the requests started in any one minute
+	MaxConcurrent     int // Requests in flight at once
+}
+
+// RateLimiter enforces a RateLimit for the requests of one provider. The limits are
+// applied over a sliding one minute window.
+type RateLimiter struct {
+	limit RateLimit
+	slots chan struct{}    // one entry per request in flight, nil when not limited
+	now   func() time.Time // the current time, replaced in tests
+
+	mu     sync.Mutex
+	window []*rateEntry // requests started in the last minute, oldest first
+}
+
+// rateEntry is a request counted against a RateLimiter
+type rateEntry struct {
+	start  time.Time
+	tokens int
+}
+
+// NewRateLimiter creates a limiter enforcing limit
+func NewRateLimiter(limit RateLimit) *RateLimiter {
+	l := &RateLimiter{limit: limit, now: time.Now}
+	if limit.MaxConcurrent > 0 {
+		l.slots = make(chan struct{}, limit.MaxConcurrent)
+	}
+	return l
+}
+
+// Wait blocks until a request expected to use the given number of tokens may be sent,
+// or until ctx is done. Once it returns without error, done must be called when the
+// request has finished, with the number of tokens the request used in place of the
+// estimate. A request larger than the whole tokens per minute limit is sent once no
+// other requests are counted against the limit.
+func (l *RateLimiter) Wait(ctx context.Context, tokens int) (done func(usedTokens int), err error) {
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	for {
+		entry, delay := l.reserve(tokens)
+		if entry != nil {
+			return func(usedTokens int) {
+				l.mu.Lock()
+				entry.tokens = usedTokens
+				l.mu.Unlock()
+				if l.slots != nil {
+					<-l.slots
+				}
+			}, nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			if l.slots != nil {
+				<-l.slots
+			}
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// reserve counts a request against the limits if they allow it now. Otherwise it
+// returns how long until they might.
+func (l *RateLimiter) reserve(tokens int) (*rateEntry, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for len(l.window) > 0 && now.Sub(l.window[0].start) >= time.Minute {
+		l.window = l.window[1:]
+	}
+
+	var delay time.Duration
+	if l.limit.RequestsPerMinute > 0 && len(l.window) >= l.limit.RequestsPerMinute {
+		oldest := l.window[len(l.window)-l.limit.RequestsPerMinute]
+		delay = max(delay, oldest.start.Add(time.Minute).Sub(now))
+	}
+	if l.limit.TokensPerMinute > 0 && len(l.window) > 0 {
+		used := 0
+		for _, entry := range l.window {
+			used += entry.tokens
+		}
+
+		// Wait for the oldest requests to leave the window until enough tokens are free
+		for _, entry := range l.window {
+			if used+tokens <= l.limit.TokensPerMinute {
+				break
+			}
+			used -= entry.tokens
+			delay = max(delay, entry.start.Add(time.Minute).Sub(now))
+		}
+	}
+
+	if delay > 0 {
+		return nil, delay
+	}
+
+	entry := &rateEntry{start: now, tokens: tokens}
+	l.window = append(l.window, entry)
+	return entry, 0
+}
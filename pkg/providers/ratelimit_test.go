@@ -0,0 +1,232 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock for RateLimiter that only moves when advanced
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) advance(d time.Duration) { c.now = c.now.Add(d) }
+
+// newTestLimiter returns a limiter using a fake clock
+func newTestLimiter(limit RateLimit) (*RateLimiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	l := NewRateLimiter(limit)
+	l.now = clock.Now
+	return l, clock
+}
+
+// step is one request made against a limiter, after moving the clock on
+type step struct {
+	after     time.Duration // clock advance before the request
+	tokens    int           // tokens the request is expected to use
+	used      int           // tokens reported when it finishes, or -1 to keep the estimate
+	wantDelay time.Duration // 0 when the request may be sent now
+}
+
+func TestRateLimiterReserve(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit RateLimit
+		steps []step
+	}{
+		{
+			name:  "no limits",
+			limit: RateLimit{},
+			steps: []step{{tokens: 1_000_000, used: -1}, {tokens: 1_000_000, used: -1}},
+		},
+		{
+			name:  "requests per minute",
+			limit: RateLimit{RequestsPerMinute: 2},
+			steps: []step{
+				{used: -1},
+				{after: 10 * time.Second, used: -1},
+				{after: 20 * time.Second, wantDelay: 30 * time.Second},
+				{after: 30 * time.Second, used: -1}, // the first request has left the window
+				{wantDelay: 10 * time.Second},       // the second leaves 10s later
+				{after: 10 * time.Second, used: -1},
+			},
+		},
+		{
+			name:  "tokens per minute",
+			limit: RateLimit{TokensPerMinute: 100},
+			steps: []step{
+				{tokens: 60, used: -1},
+				{after: 15 * time.Second, tokens: 30, used: -1},
+				{after: 15 * time.Second, tokens: 20, wantDelay: 30 * time.Second},
+				{tokens: 10, used: -1}, // still fits
+				{after: 30 * time.Second, tokens: 60, used: -1},
+			},
+		},
+		{
+			name:  "waits for enough of the oldest requests to leave",
+			limit: RateLimit{TokensPerMinute: 100},
+			steps: []step{
+				{tokens: 40, used: -1},
+				{after: 10 * time.Second, tokens: 40, used: -1},
+				{after: 10 * time.Second, tokens: 90, wantDelay: 50 * time.Second},
+			},
+		},
+		{
+			name:  "used tokens replace the estimate",
+			limit: RateLimit{TokensPerMinute: 100},
+			steps: []step{
+				{tokens: 90, used: 20},
+				{tokens: 80, used: -1},
+				{tokens: 1, wantDelay: time.Minute},
+			},
+		},
+		{
+			name:  "request larger than the limit",
+			limit: RateLimit{TokensPerMinute: 100},
+			steps: []step{
+				{tokens: 500, used: -1}, // sent alone
+				{after: 30 * time.Second, tokens: 1, wantDelay: 30 * time.Second},
+				{after: 30 * time.Second, tokens: 500, used: -1},
+			},
+		},
+		{
+			name:  "both limits",
+			limit: RateLimit{RequestsPerMinute: 1, TokensPerMinute: 100},
+			steps: []step{
+				{tokens: 10, used: -1},
+				{after: 40 * time.Second, tokens: 10, wantDelay: 20 * time.Second},
+				{after: 20 * time.Second, tokens: 10, used: -1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, clock := newTestLimiter(tt.limit)
+			for i, s := range tt.steps {
+				clock.advance(s.after)
+				entry, delay := l.reserve(s.tokens)
+				if delay != s.wantDelay {
+					t.Fatalf("step %d: delay = %v, want %v", i, delay, s.wantDelay)
+				}
+				if (entry == nil) != (s.wantDelay > 0) {
+					t.Fatalf("step %d: reserved = %v, want %v", i, entry != nil, s.wantDelay == 0)
+				}
+				if entry != nil && s.used >= 0 {
+					entry.tokens = s.used
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	l, clock := newTestLimiter(RateLimit{RequestsPerMinute: 1, TokensPerMinute: 100})
+
+	done, err := l.Wait(context.Background(), 80)
+	if err != nil {
+		t.Fatalf("first Wait: %v", err)
+	}
+	done(20)
+
+	// The request limit is reached, so a cancelled wait gives up with the context's error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := l.Wait(ctx, 10); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wait with a cancelled context: err = %v, want context.Canceled", err)
+	}
+
+	// Once the first request leaves the window, the reported 20 tokens no longer count
+	clock.advance(time.Minute)
+	if _, err := l.Wait(context.Background(), 100); err != nil {
+		t.Fatalf("Wait after a minute: %v", err)
+	}
+}
+
+func TestRateLimiterMaxConcurrent(t *testing.T) {
+	l, _ := newTestLimiter(RateLimit{MaxConcurrent: 2})
+
+	first, err := l.Wait(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Wait(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := l.Wait(ctx, 0); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wait with two requests in flight: err = %v, want context.Canceled", err)
+	}
+
+	// Finishing a request frees its slot
+	first(0)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := l.Wait(ctx, 0); err != nil {
+		t.Fatalf("Wait after a request finished: %v", err)
+	}
+}
+
+// countingProvider counts its calls and answers each with a fixed response, or
+// fails them with err when it is set
+type countingProvider struct {
+	calls int
+	err   error
+}
+
+func (p *countingProvider) Name() string { return "counting" }
+
+func (p *countingProvider) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &CompletionResponse{Content: "ok", InputTokens: 10, OutputTokens: 5, InputCost: 0.01, OutputCost: 0.02}, nil
+}
+
+func TestRegistryRateLimitSkipsCacheHits(t *testing.T) {
+	provider := &countingProvider{}
+	registry := NewRegistry().WithCache(NewCache(t.TempDir(), 0))
+	registry.Register(provider)
+	registry.WithRateLimit("counting", RateLimit{RequestsPerMinute: 1})
+
+	p, _ := registry.Get("counting")
+	req := CompletionRequest{Prompt: "hello", Model: "m"}
+
+	var throttled []time.Duration
+	ctx := WithCallHooks(context.Background(), CallHooks{
+		Throttled: func(wait time.Duration) { throttled = append(throttled, wait) },
+	})
+	if _, err := p.Complete(ctx, req); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+
+	// The limit of one request per minute is used up, so only a cache hit can answer
+	// before the deadline
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	resp, err := p.Complete(ctx, req)
+	if err != nil {
+		t.Fatalf("cached call: %v", err)
+	}
+	if !resp.Cached {
+		t.Errorf("second call was not served from the cache")
+	}
+	if provider.calls != 1 {
+		t.Errorf("provider called %d times, want 1", provider.calls)
+	}
+	if len(throttled) != 1 {
+		t.Errorf("Throttled called %d times, want once for the call sent to the provider", len(throttled))
+	}
+
+	// A different request is sent and has to wait for the limit
+	if _, err := p.Complete(ctx, CompletionRequest{Prompt: "other", Model: "m"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("uncached call: err = %v, want context.DeadlineExceeded", err)
+	}
+}
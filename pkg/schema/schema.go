@@ -0,0 +1,405 @@
+// Package schema validates JSON values against JSON Schema, as used by the `schema:`
+// setting of llm nodes to check model responses.
+//
+// The subset of JSON Schema needed to describe structured responses is supported:
+//   - type, as a single type or a list of types: object, array, string, number,
+//     integer, boolean or null
+//   - Objects: properties, required and additionalProperties (a bool or a schema)
+//   - Arrays: items, minItems and maxItems
+//   - Strings: minLength, maxLength and pattern
+//   - Numbers: minimum, maximum, exclusiveMinimum and exclusiveMaximum
+//   - Any value: enum, const, anyOf and allOf
+//
+// Other keywords, such as description or title, are ignored.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is a parsed JSON Schema
+type Schema struct {
+	types                []string
+	properties           map[string]*Schema
+	required             []string
+	additionalProperties *Schema // nil when any additional property is allowed
+	noAdditional         bool    // additionalProperties is false
+	items                *Schema
+	enum                 []any
+	constant             any
+	hasConst             bool
+	minLength, maxLength *int
+	pattern              *regexp.Regexp
+	minimum, maximum     *float64
+	exclusiveMinimum     *float64
+	exclusiveMaximum     *float64
+	minItems, maxItems   *int
+	anyOf, allOf         []*Schema
+
+	source map[string]any
+}
+
+// types accepted by the type keyword
+var validTypes = []string{"object", "array", "string", "number", "integer", "boolean", "null"}
+
+// Parse parses a schema from its decoded JSON or YAML form
+func Parse(def map[string]any) (*Schema, error) {
+	return parse(def, "$")
+}
+
+func parse(def map[string]any, path string) (*Schema, error) {
+	s := &Schema{source: def}
+	var err error
+
+	if t, ok := def["type"]; ok {
+		switch v := t.(type) {
+		case string:
+			s.types = []string{v}
+		case []any:
+			for _, item := range v {
+				name, ok := item.(string)
+				if !ok {
+					return nil, fmt.Errorf("%s.type: expected a string or list of strings", path)
+				}
+				s.types = append(s.types, name)
+			}
+		default:
+			return nil, fmt.Errorf("%s.type: expected a string or list of strings", path)
+		}
+		for _, name := range s.types {
+			if !slices.Contains(validTypes, name) {
+				return nil, fmt.Errorf("%s.type: unknown type %q (expected one of %v)", path, name, validTypes)
+			}
+		}
+	}
+
+	if p, ok := def["properties"]; ok {
+		props, ok := p.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s.properties: expected an object", path)
+		}
+		s.properties = make(map[string]*Schema, len(props))
+		for name, propDef := range props {
+			if s.properties[name], err = parseSub(propDef, path+".properties."+name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if r, ok := def["required"]; ok {
+		list, ok := r.([]any)
+		if !ok {
+			return nil, fmt.Errorf("%s.required: expected a list of strings", path)
+		}
+		for _, item := range list {
+			name, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s.required: expected a list of strings", path)
+			}
+			s.required = append(s.required, name)
+		}
+	}
+
+	if a, ok := def["additionalProperties"]; ok {
+		if allowed, ok := a.(bool); ok {
+			s.noAdditional = !allowed
+		} else if s.additionalProperties, err = parseSub(a, path+".additionalProperties"); err != nil {
+			return nil, err
+		}
+	}
+
+	if i, ok := def["items"]; ok {
+		if s.items, err = parseSub(i, path+".items"); err != nil {
+			return nil, err
+		}
+	}
+
+	if e, ok := def["enum"]; ok {
+		list, ok := e.([]any)
+		if !ok {
+			return nil, fmt.Errorf("%s.enum: expected a list", path)
+		}
+		s.enum = list
+	}
+
+	if c, ok := def["const"]; ok {
+		s.constant, s.hasConst = c, true
+	}
+
+	for _, kw := range []struct {
+		name string
+		dst  **int
+	}{
+		{"minLength", &s.minLength},
+		{"maxLength", &s.maxLength},
+		{"minItems", &s.minItems},
+		{"maxItems", &s.maxItems},
+	} {
+		if v, ok := def[kw.name]; ok {
+			n, ok := toNumber(v)
+			if !ok || n < 0 || n != math.Trunc(n) {
+				return nil, fmt.Errorf("%s.%s: expected a non-negative integer", path, kw.name)
+			}
+			i := int(n)
+			*kw.dst = &i
+		}
+	}
+
+	for _, kw := range []struct {
+		name string
+		dst  **float64
+	}{
+		{"minimum", &s.minimum},
+		{"maximum", &s.maximum},
+		{"exclusiveMinimum", &s.exclusiveMinimum},
+		{"exclusiveMaximum", &s.exclusiveMaximum},
+	} {
+		if v, ok := def[kw.name]; ok {
+			n, ok := toNumber(v)
+			if !ok {
+				return nil, fmt.Errorf("%s.%s: expected a number", path, kw.name)
+			}
+			*kw.dst = &n
+		}
+	}
+
+	if p, ok := def["pattern"]; ok {
+		text, ok := p.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s.pattern: expected a string", path)
+		}
+		if s.pattern, err = regexp.Compile(text); err != nil {
+			return nil, fmt.Errorf("%s.pattern: %w", path, err)
+		}
+	}
+
+	for _, kw := range []struct {
+		name string
+		dst  *[]*Schema
+	}{
+		{"anyOf", &s.anyOf},
+		{"allOf", &s.allOf},
+	} {
+		v, ok := def[kw.name]
+		if !ok {
+			continue
+		}
+		list, ok := v.([]any)
+		if !ok || len(list) == 0 {
+			return nil, fmt.Errorf("%s.%s: expected a non-empty list of schemas", path, kw.name)
+		}
+		for i, subDef := range list {
+			sub, err := parseSub(subDef, fmt.Sprintf("%s.%s[%d]", path, kw.name, i))
+			if err != nil {
+				return nil, err
+			}
+			*kw.dst = append(*kw.dst, sub)
+		}
+	}
+
+	return s, nil
+}
+
+// parseSub parses a schema nested in another
+func parseSub(def any, path string) (*Schema, error) {
+	m, ok := def.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected a schema object", path)
+	}
+	return parse(m, path)
+}
+
+// String returns the schema as JSON
+func (s *Schema) String() string {
+	data, err := json.Marshal(s.source)
+	if err != nil {
+		return fmt.Sprint(s.source)
+	}
+	return string(data)
+}
+
+// Validate checks a decoded JSON value against the schema. It returns a description of
+// every mismatch found, each prefixed with the path of the offending value (e.g.
+// "$.items[2].name: expected a string, got number"), or nil if the value matches.
+func (s *Schema) Validate(value any) []string {
+	var errs []string
+	s.validate(value, "$", &errs)
+	return errs
+}
+
+func (s *Schema) validate(value any, path string, errs *[]string) {
+	report := func(format string, args ...any) {
+		*errs = append(*errs, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if len(s.types) > 0 && !slices.ContainsFunc(s.types, func(t string) bool { return hasType(value, t) }) {
+		report("expected %s, got %s", strings.Join(s.types, " or "), typeName(value))
+		return
+	}
+
+	if s.enum != nil && !slices.ContainsFunc(s.enum, func(v any) bool { return equal(v, value) }) {
+		report("must be one of %s", toJSON(s.enum))
+	}
+	if s.hasConst && !equal(s.constant, value) {
+		report("must be %s", toJSON(s.constant))
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		for _, name := range s.required {
+			if _, ok := v[name]; !ok {
+				report("missing required property %q", name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			propPath := path + "." + name
+			if prop, ok := s.properties[name]; ok {
+				prop.validate(v[name], propPath, errs)
+			} else if s.noAdditional {
+				report("unexpected property %q", name)
+			} else if s.additionalProperties != nil {
+				s.additionalProperties.validate(v[name], propPath, errs)
+			}
+		}
+
+	case []any:
+		if s.minItems != nil && len(v) < *s.minItems {
+			report("expected at least %d items, got %d", *s.minItems, len(v))
+		}
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			report("expected at most %d items, got %d", *s.maxItems, len(v))
+		}
+		if s.items != nil {
+			for i, item := range v {
+				s.items.validate(item, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+
+	case string:
+		length := utf8.RuneCountInString(v)
+		if s.minLength != nil && length < *s.minLength {
+			report("expected at least %d characters, got %d", *s.minLength, length)
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			report("expected at most %d characters, got %d", *s.maxLength, length)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			report("does not match pattern %q", s.pattern.String())
+		}
+	}
+
+	if n, ok := toNumber(value); ok {
+		if s.minimum != nil && n < *s.minimum {
+			report("must be at least %v", *s.minimum)
+		}
+		if s.maximum != nil && n > *s.maximum {
+			report("must be at most %v", *s.maximum)
+		}
+		if s.exclusiveMinimum != nil && n <= *s.exclusiveMinimum {
+			report("must be greater than %v", *s.exclusiveMinimum)
+		}
+		if s.exclusiveMaximum != nil && n >= *s.exclusiveMaximum {
+			report("must be less than %v", *s.exclusiveMaximum)
+		}
+	}
+
+	for _, sub := range s.allOf {
+		sub.validate(value, path, errs)
+	}
+	if len(s.anyOf) > 0 && !slices.ContainsFunc(s.anyOf, func(sub *Schema) bool { return len(sub.Validate(value)) == 0 }) {
+		report("does not match any of the allowed schemas")
+	}
+}
+
+// hasType reports whether a decoded JSON value is of the given JSON Schema type
+func hasType(value any, typ string) bool {
+	switch typ {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := toNumber(value)
+		return ok
+	case "integer":
+		n, ok := toNumber(value)
+		return ok && n == math.Trunc(n)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	}
+	return false
+}
+
+// typeName returns the JSON Schema type of a decoded JSON value, for error messages
+func typeName(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+	if _, ok := toNumber(value); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// toNumber converts a decoded JSON or YAML number to a float64
+func toNumber(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// equal compares two decoded values, treating numbers of different Go types as equal
+// when their values are
+func equal(a, b any) bool {
+	if x, ok := toNumber(a); ok {
+		y, ok := toNumber(b)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toJSON(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
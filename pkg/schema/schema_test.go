@@ -0,0 +1,239 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// decode decodes JSON text the way a response or schema file is decoded
+func decode(t *testing.T, text string) any {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal([]byte(text), &v); err != nil {
+		t.Fatalf("decoding %s: %v", text, err)
+	}
+	return v
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		value  string
+		want   []string
+	}{
+		// type
+		{name: "type matches", schema: `{"type": "string"}`, value: `"x"`},
+		{name: "type mismatch", schema: `{"type": "string"}`, value: `1`, want: []string{"$: expected string, got number"}},
+		{name: "type list", schema: `{"type": ["string", "null"]}`, value: `null`},
+		{name: "type list mismatch", schema: `{"type": ["string", "null"]}`, value: `true`, want: []string{"$: expected string or null, got boolean"}},
+		{name: "integer", schema: `{"type": "integer"}`, value: `3`},
+		{name: "integer with fraction", schema: `{"type": "integer"}`, value: `3.5`, want: []string{"$: expected integer, got number"}},
+		{name: "number accepts integer", schema: `{"type": "number"}`, value: `3`},
+		{name: "object", schema: `{"type": "object"}`, value: `[]`, want: []string{"$: expected object, got array"}},
+		{name: "array", schema: `{"type": "array"}`, value: `{}`, want: []string{"$: expected array, got object"}},
+		{name: "boolean", schema: `{"type": "boolean"}`, value: `"true"`, want: []string{"$: expected boolean, got string"}},
+		{name: "null", schema: `{"type": "null"}`, value: `0`, want: []string{"$: expected null, got number"}},
+		{name: "no type accepts anything", schema: `{}`, value: `{"a": [1, "b"]}`},
+
+		// required
+		{name: "required present", schema: `{"type": "object", "required": ["a"]}`, value: `{"a": null}`},
+		{
+			name:   "required missing",
+			schema: `{"type": "object", "required": ["a", "b"]}`,
+			value:  `{"b": 1}`,
+			want:   []string{`$: missing required property "a"`},
+		},
+		{name: "required ignored for non-objects", schema: `{"required": ["a"]}`, value: `"x"`},
+
+		// properties
+		{
+			name:   "properties validated",
+			schema: `{"type": "object", "properties": {"name": {"type": "string"}, "age": {"type": "integer"}}}`,
+			value:  `{"name": 1, "age": "x"}`,
+			want:   []string{"$.age: expected integer, got string", "$.name: expected string, got number"},
+		},
+		{
+			name:   "nested properties",
+			schema: `{"properties": {"user": {"properties": {"id": {"type": "string"}}}}}`,
+			value:  `{"user": {"id": 7}}`,
+			want:   []string{"$.user.id: expected string, got number"},
+		},
+		{name: "missing optional property", schema: `{"properties": {"name": {"type": "string"}}}`, value: `{}`},
+
+		// additionalProperties
+		{name: "additional allowed by default", schema: `{"properties": {"a": {}}}`, value: `{"a": 1, "b": 2}`},
+		{
+			name:   "additional false",
+			schema: `{"properties": {"a": {}}, "additionalProperties": false}`,
+			value:  `{"a": 1, "c": 3, "b": 2}`,
+			want:   []string{`$: unexpected property "b"`, `$: unexpected property "c"`},
+		},
+		{name: "additional true", schema: `{"properties": {"a": {}}, "additionalProperties": true}`, value: `{"b": 2}`},
+		{
+			name:   "additional schema",
+			schema: `{"additionalProperties": {"type": "number"}}`,
+			value:  `{"a": 1, "b": "two"}`,
+			want:   []string{"$.b: expected number, got string"},
+		},
+
+		// items, minItems and maxItems
+		{
+			name:   "items",
+			schema: `{"type": "array", "items": {"type": "string"}}`,
+			value:  `["a", 2, "c", false]`,
+			want:   []string{"$[1]: expected string, got number", "$[3]: expected string, got boolean"},
+		},
+		{
+			name:   "items of objects",
+			schema: `{"items": {"required": ["sku"]}}`,
+			value:  `[{"sku": "x"}, {}]`,
+			want:   []string{`$[1]: missing required property "sku"`},
+		},
+		{name: "minItems", schema: `{"minItems": 2}`, value: `[1]`, want: []string{"$: expected at least 2 items, got 1"}},
+		{name: "maxItems", schema: `{"maxItems": 1}`, value: `[1, 2]`, want: []string{"$: expected at most 1 items, got 2"}},
+		{name: "item count in range", schema: `{"minItems": 1, "maxItems": 2}`, value: `[1, 2]`},
+
+		// enum and const
+		{name: "enum match", schema: `{"enum": ["low", "high"]}`, value: `"high"`},
+		{name: "enum mismatch", schema: `{"enum": ["low", "high"]}`, value: `"medium"`, want: []string{`$: must be one of ["low","high"]`}},
+		{name: "enum of numbers", schema: `{"enum": [1, 2]}`, value: `2.0`},
+		{name: "enum of objects", schema: `{"enum": [{"a": 1}]}`, value: `{"a": 1}`},
+		{name: "const match", schema: `{"const": "yes"}`, value: `"yes"`},
+		{name: "const mismatch", schema: `{"const": "yes"}`, value: `"no"`, want: []string{`$: must be "yes"`}},
+		{name: "const null", schema: `{"const": null}`, value: `0`, want: []string{`$: must be null`}},
+
+		// minimum and maximum
+		{name: "minimum", schema: `{"minimum": 0}`, value: `-1`, want: []string{"$: must be at least 0"}},
+		{name: "minimum inclusive", schema: `{"minimum": 0}`, value: `0`},
+		{name: "maximum", schema: `{"maximum": 1}`, value: `1.5`, want: []string{"$: must be at most 1"}},
+		{name: "maximum inclusive", schema: `{"maximum": 1}`, value: `1`},
+		{name: "exclusiveMinimum", schema: `{"exclusiveMinimum": 0}`, value: `0`, want: []string{"$: must be greater than 0"}},
+		{name: "exclusiveMaximum", schema: `{"exclusiveMaximum": 1}`, value: `1`, want: []string{"$: must be less than 1"}},
+		{name: "bounds ignored for strings", schema: `{"minimum": 5}`, value: `"1"`},
+
+		// strings
+		{name: "minLength", schema: `{"minLength": 3}`, value: `"ab"`, want: []string{"$: expected at least 3 characters, got 2"}},
+		{name: "maxLength", schema: `{"maxLength": 2}`, value: `"abc"`, want: []string{"$: expected at most 2 characters, got 3"}},
+		{name: "length counts characters", schema: `{"maxLength": 2}`, value: `"é✓"`},
+		{name: "pattern", schema: `{"pattern": "^[A-Z]+-\\d+$"}`, value: `"AB-12"`},
+		{name: "pattern mismatch", schema: `{"pattern": "^[A-Z]+$"}`, value: `"ab"`, want: []string{`$: does not match pattern "^[A-Z]+$"`}},
+
+		// anyOf and allOf
+		{name: "anyOf match", schema: `{"anyOf": [{"type": "string"}, {"type": "number"}]}`, value: `1`},
+		{
+			name:   "anyOf mismatch",
+			schema: `{"anyOf": [{"type": "string"}, {"type": "number"}]}`,
+			value:  `true`,
+			want:   []string{"$: does not match any of the allowed schemas"},
+		},
+		{
+			name:   "allOf",
+			schema: `{"allOf": [{"minLength": 2}, {"pattern": "^a"}]}`,
+			value:  `"b"`,
+			want:   []string{"$: expected at least 2 characters, got 1", `$: does not match pattern "^a"`},
+		},
+
+		// Several mismatches are all reported
+		{
+			name: "every mismatch reported",
+			schema: `{
+				"type": "object",
+				"required": ["urgency", "reason"],
+				"properties": {
+					"urgency": {"enum": ["low", "high"]},
+					"score": {"type": "number", "minimum": 0, "maximum": 1}
+				},
+				"additionalProperties": false
+			}`,
+			value: `{"urgency": "urgent", "score": 2, "extra": true}`,
+			want: []string{
+				`$: missing required property "reason"`,
+				`$: unexpected property "extra"`,
+				"$.score: must be at most 1",
+				`$.urgency: must be one of ["low","high"]`,
+			},
+		},
+		{
+			name:   "type mismatch stops other checks",
+			schema: `{"type": "object", "required": ["a"], "enum": [{"a": 1}]}`,
+			value:  `"a"`,
+			want:   []string{"$: expected object, got string"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(decode(t, tt.schema).(map[string]any))
+			if err != nil {
+				t.Fatalf("Parse() unexpected error: %v", err)
+			}
+			got := s.Validate(decode(t, tt.value))
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate(%s) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateYAMLNumbers(t *testing.T) {
+	// Schemas and values decoded from YAML hold integers rather than float64
+	s, err := Parse(map[string]any{"type": "integer", "minimum": 1, "enum": []any{1, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := s.Validate(2.0); errs != nil {
+		t.Errorf("Validate(2.0) = %q, want no mismatches", errs)
+	}
+	if errs := s.Validate(int64(3)); !reflect.DeepEqual(errs, []string{"$: must be one of [1,2]"}) {
+		t.Errorf("Validate(3) = %q", errs)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		wantErr string
+	}{
+		{name: "type not a string", schema: `{"type": 1}`, wantErr: "$.type: expected a string or list of strings"},
+		{name: "type list item not a string", schema: `{"type": ["string", 1]}`, wantErr: "$.type: expected a string or list of strings"},
+		{name: "unknown type", schema: `{"type": "text"}`, wantErr: `$.type: unknown type "text"`},
+		{name: "properties not an object", schema: `{"properties": []}`, wantErr: "$.properties: expected an object"},
+		{name: "property not a schema", schema: `{"properties": {"a": "string"}}`, wantErr: "$.properties.a: expected a schema object"},
+		{name: "nested property error", schema: `{"properties": {"a": {"type": "str"}}}`, wantErr: `$.properties.a.type: unknown type "str"`},
+		{name: "required not a list", schema: `{"required": "a"}`, wantErr: "$.required: expected a list of strings"},
+		{name: "required item not a string", schema: `{"required": [1]}`, wantErr: "$.required: expected a list of strings"},
+		{name: "additionalProperties not a schema", schema: `{"additionalProperties": "no"}`, wantErr: "$.additionalProperties: expected a schema object"},
+		{name: "items not a schema", schema: `{"items": []}`, wantErr: "$.items: expected a schema object"},
+		{name: "items error", schema: `{"items": {"minItems": -1}}`, wantErr: "$.items.minItems: expected a non-negative integer"},
+		{name: "enum not a list", schema: `{"enum": "a"}`, wantErr: "$.enum: expected a list"},
+		{name: "minLength negative", schema: `{"minLength": -1}`, wantErr: "$.minLength: expected a non-negative integer"},
+		{name: "maxLength fraction", schema: `{"maxLength": 1.5}`, wantErr: "$.maxLength: expected a non-negative integer"},
+		{name: "minItems not a number", schema: `{"minItems": "2"}`, wantErr: "$.minItems: expected a non-negative integer"},
+		{name: "maxItems negative", schema: `{"maxItems": -2}`, wantErr: "$.maxItems: expected a non-negative integer"},
+		{name: "minimum not a number", schema: `{"minimum": "0"}`, wantErr: "$.minimum: expected a number"},
+		{name: "maximum not a number", schema: `{"maximum": true}`, wantErr: "$.maximum: expected a number"},
+		{name: "exclusiveMinimum not a number", schema: `{"exclusiveMinimum": null}`, wantErr: "$.exclusiveMinimum: expected a number"},
+		{name: "exclusiveMaximum not a number", schema: `{"exclusiveMaximum": []}`, wantErr: "$.exclusiveMaximum: expected a number"},
+		{name: "pattern not a string", schema: `{"pattern": 1}`, wantErr: "$.pattern: expected a string"},
+		{name: "pattern does not compile", schema: `{"pattern": "("}`, wantErr: "$.pattern: error parsing regexp"},
+		{name: "anyOf empty", schema: `{"anyOf": []}`, wantErr: "$.anyOf: expected a non-empty list of schemas"},
+		{name: "allOf not a list", schema: `{"allOf": {}}`, wantErr: "$.allOf: expected a non-empty list of schemas"},
+		{name: "anyOf item not a schema", schema: `{"anyOf": [{}, 1]}`, wantErr: "$.anyOf[1]: expected a schema object"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(decode(t, tt.schema).(map[string]any))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Parse(%s) error = %v, want it to contain %q", tt.schema, err, tt.wantErr)
+			}
+		})
+	}
+}
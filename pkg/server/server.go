@@ -68,6 +68,40 @@ func (s *Server) Start() error {
 	return server.ListenAndServe()
 }
 
+// parseFlowBytes parses a flow sent by the web UI. The flow is treated as if it lived
+// at the served flow's path, so relative paths such as subflow and prompt files still
+// resolve.
+func (s *Server) parseFlowBytes(data []byte) (*flow.Flow, error) {
+	f, err := flow.ParseBytes(data, "flow.yaml")
+	if err != nil {
+		return nil, err
+	}
+	if s.flowPath != "" {
+		f.FilePath = s.flowPath
+	}
+	return f, nil
+}
+
+// resolvePrompts fills in the ResolvedPrompt of every node with a prompt file, so the
+// web UI can show the prompt. Files that cannot be read are left for validation to
+// report.
+func resolvePrompts(f *flow.Flow) {
+	for i := range f.Nodes {
+		nodes := []*flow.Node{&f.Nodes[i]}
+		if f.Nodes[i].Map != nil && f.Nodes[i].Map.Node != nil {
+			nodes = append(nodes, f.Nodes[i].Map.Node)
+		}
+		for _, node := range nodes {
+			if node.PromptFile == "" {
+				continue
+			}
+			if prompt, err := f.LoadPrompt(node); err == nil {
+				node.ResolvedPrompt = prompt
+			}
+		}
+	}
+}
+
 // handleGetFlow returns the flow definition
 func (s *Server) handleGetFlow(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet && r.Method != http.MethodPost {
@@ -87,7 +121,7 @@ func (s *Server) handleGetFlow(w http.ResponseWriter, r *http.Request) {
 		}
 		defer r.Body.Close()
 
-		f, err = flow.ParseBytes(body, "flow.yaml")
+		f, err = s.parseFlowBytes(body)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Failed to parse flow: %v", err), http.StatusBadRequest)
 			return
@@ -106,6 +140,7 @@ func (s *Server) handleGetFlow(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	resolvePrompts(f)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(f)
 }
@@ -124,7 +159,7 @@ func (s *Server) handleValidateFlow(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
-	f, err := flow.ParseBytes(body, "flow.yaml")
+	f, err := s.parseFlowBytes(body)
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]any{
@@ -161,23 +196,45 @@ func (s *Server) handleExecuteFlow(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Flow   json.RawMessage `json:"flow"`
 		Inputs map[string]any  `json:"inputs"`
+
+		// To re-run from a node, the result of a previous run and the node to start at
+		Previous *flow.ExecutionResult `json:"previous,omitempty"`
+		StartAt  string                `json:"start_at,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to parse request: %v", err), http.StatusBadRequest)
 		return
 	}
+	if req.StartAt != "" && req.Previous == nil {
+		http.Error(w, "start_at requires the previous run result", http.StatusBadRequest)
+		return
+	}
+	if req.Previous != nil && req.StartAt == "" {
+		http.Error(w, "previous requires start_at, the node to re-run from", http.StatusBadRequest)
+		return
+	}
 
-	f, err := flow.ParseBytes(req.Flow, "flow.yaml")
+	f, err := s.parseFlowBytes(req.Flow)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to parse flow: %v", err), http.StatusBadRequest)
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	// Flows that set their own timeout are given that long, otherwise five minutes
+	timeout := 5 * time.Minute
+	if f.Config.Timeout > 0 {
+		timeout = time.Duration(f.Config.Timeout)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	result, err := s.executor.Execute(ctx, f, req.Inputs)
+	var result *flow.ExecutionResult
+	if req.StartAt != "" {
+		result, err = s.executor.ExecuteFrom(ctx, f, req.Inputs, req.Previous, req.StartAt)
+	} else {
+		result, err = s.executor.Execute(ctx, f, req.Inputs)
+	}
 	if err != nil {
 		// Still return the result even if there's an error
 		w.Header().Set("Content-Type", "application/json")
@@ -6,7 +6,7 @@ import { FlowCanvas } from './components/FlowCanvas';
 import { useFlow } from './hooks/useFlow';
 import { useConfig } from './hooks/useConfig';
 import { api } from './services/api';
-import type { FlowNode, ExecutionResult } from './types/flow';
+import type { FlowNode, FlowInput, ExecutionResult } from './types/flow';
 import './App.css';
 
 function App() {
@@ -20,9 +20,10 @@ function App() {
   );
   const [executionError, setExecutionError] = useState<string | null>(null);
 
-  // Extract root-level inputs from the flow definition
-  const rootInputs = useMemo(() => {
+  // Use the flow's declared inputs, or extract them from the nodes when it has none
+  const rootInputs = useMemo((): FlowInput[] => {
     if (!flow) return [];
+    if (flow.inputs) return flow.inputs;
 
     const rootInputSet = new Set<string>();
     flow.nodes.forEach(node => {
@@ -33,7 +34,7 @@ function App() {
       });
     });
 
-    return Array.from(rootInputSet);
+    return Array.from(rootInputSet, name => ({ name }));
   }, [flow]);
 
   const handleInputChange = (key: string, value: string) => {
@@ -43,9 +44,12 @@ function App() {
     }));
   };
 
-  const handleExecuteFlow = async () => {
+  // Runs the whole flow, or re-runs it from startAt reusing the latest result
+  const handleExecuteFlow = async (startAt?: string) => {
     if (!flow) return;
 
+    const previous = startAt ? executionResult ?? undefined : undefined;
+
     setExecuting(true);
     setExecutionResult(null);
     setExecutionError(null);
@@ -54,6 +58,8 @@ function App() {
       const result = await api.executeFlow({
         flow,
         inputs,
+        previous,
+        start_at: previous ? startAt : undefined,
       });
       setExecutionResult(result);
     } catch (err) {
@@ -102,6 +108,7 @@ function App() {
           flow={flow}
           onNodeSelect={handleNodeSelect}
           showStartEndNode={config?.showStartEndNode}
+          executionResult={executionResult}
         />
       </div>
 
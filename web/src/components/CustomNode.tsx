@@ -11,16 +11,28 @@ interface CustomNodeProps {
   selected?: boolean;
 }
 
+// Visual styles for node types that should stand out from regular LLM nodes
+const nodeTypeStyles: Record<string, { borderStyle: string; background: string }> = {
+  template: { borderStyle: 'dashed', background: '#f3f7ee' },
+  http: { borderStyle: 'solid', background: '#eef4fb' },
+  switch: { borderStyle: 'double', background: '#fdf6e3' },
+  map: { borderStyle: 'double', background: '#f1eefb' },
+  subflow: { borderStyle: 'double', background: '#eef7f6' },
+};
+
 export const CustomNode = memo(({ data, selected }: CustomNodeProps) => {
   const { node, hasInputsFromNodes = true, hasOutputsToNodes = true } = data;
+  const typeStyle = nodeTypeStyles[node.type || 'llm'];
 
   return (
     <div
+      title={node.type || 'llm'}
       style={{
         padding: '12px 16px',
         borderRadius: '8px',
         border: '2px solid #333',
-        background: '#fff',
+        borderStyle: typeStyle?.borderStyle ?? 'solid',
+        background: typeStyle?.background ?? '#fff',
         display: 'flex',
         alignItems: 'center',
         justifyContent: 'center',
@@ -11,7 +11,7 @@ import {
   type NodeChange,
 } from '@xyflow/react';
 import '@xyflow/react/dist/style.css';
-import type { Flow, FlowNode } from '../types/flow';
+import type { ExecutionResult, Flow, FlowNode } from '../types/flow';
 import { CustomNode } from './CustomNode';
 import { CircleNode } from './CircleNode';
 
@@ -19,6 +19,28 @@ interface FlowCanvasProps {
   flow: Flow;
   onNodeSelect: (node: FlowNode | null) => void;
   showStartEndNode?: boolean;
+  executionResult?: ExecutionResult | null;
+}
+
+// Returns the branches of a switch node as (case label, target node ID) pairs
+function switchBranches(node: FlowNode): { label: string; target: string }[] {
+  if (!node.switch) return [];
+  const branches = Object.entries(node.switch.cases || {}).map(([label, target]) => ({
+    label,
+    target,
+  }));
+  if (node.switch.default) {
+    branches.push({ label: 'default', target: node.switch.default });
+  }
+  return branches;
+}
+
+// Returns the ID of the node that handles this node's failures, if on_error names one
+function errorHandler(node: FlowNode): string | undefined {
+  if (!node.on_error || node.on_error === 'fail' || node.on_error === 'continue') {
+    return undefined;
+  }
+  return node.on_error;
 }
 
 interface NodeDimensions {
@@ -108,6 +130,21 @@ function calculateNodePositions(
     });
   });
 
+  // Switch targets are laid out below their switch node
+  nodes.forEach((node) => {
+    switchBranches(node).forEach(({ target }) => {
+      adjacencyList[target]?.push(node.id);
+    });
+  });
+
+  // Error handlers are laid out below the node they handle
+  nodes.forEach((node) => {
+    const handler = errorHandler(node);
+    if (handler) {
+      adjacencyList[handler]?.push(node.id);
+    }
+  });
+
   // Calculate levels using DFS
   function getLevel(nodeId: string): number {
     if (levels[nodeId] !== undefined) return levels[nodeId];
@@ -279,8 +316,14 @@ function buildGraphNodes(
     const dimensions = nodeDimensions.get(node.id)!;
 
     // Check if node has inputs/outputs from/to other nodes (not just "input"/"output")
-    const hasInputsFromNodes = node.inputs.some((input) => input.from !== 'input');
+    const isSwitchTarget = flowData.nodes.some((otherNode) =>
+      switchBranches(otherNode).some(({ target }) => target === node.id)
+    );
+    const isErrorHandler = flowData.nodes.some((otherNode) => errorHandler(otherNode) === node.id);
+    const hasInputsFromNodes =
+      isSwitchTarget || isErrorHandler || node.inputs.some((input) => input.from !== 'input');
     const hasOutputsToNodes = (() => {
+      if (switchBranches(node).length > 0 || errorHandler(node)) return true;
       // Check if any other node references this node's outputs
       return flowData.nodes.some((otherNode) =>
         otherNode.inputs.some((input) => {
@@ -321,12 +364,69 @@ function buildGraphNodes(
         }
       }
     });
+
+    // Create switch edges to each branch target
+    switchBranches(node).forEach(({ label, target }) => {
+      newEdges.push({
+        id: `${node.id}-switch-${target}-${label}`,
+        source: node.id,
+        target,
+        label,
+        data: { switchId: node.id, target },
+        style: { strokeDasharray: '6 4' },
+      });
+    });
+
+    // Create an error edge to the node's error handler
+    const handler = errorHandler(node);
+    if (handler) {
+      newEdges.push({
+        id: `${node.id}-error-${handler}`,
+        source: node.id,
+        target: handler,
+        label: 'on error',
+        style: { stroke: '#dc3545', strokeDasharray: '2 4' },
+        labelStyle: { fill: '#dc3545' },
+      });
+    }
   });
 
   return [newNodes, newEdges];
 }
 
-export function FlowCanvas({ flow, onNodeSelect, showStartEndNode = false }: FlowCanvasProps) {
+// Highlights the switch edges that were taken in the latest execution and dims the rest
+function applyBranchHighlights(edges: Edge[], result: ExecutionResult | null | undefined): Edge[] {
+  const branches = new Map<string, string | undefined>();
+  result?.node_results?.forEach((nodeResult) => {
+    branches.set(nodeResult.node_id, nodeResult.branch);
+  });
+
+  return edges.map((edge) => {
+    const data = edge.data as { switchId?: string; target?: string } | undefined;
+    if (!data?.switchId) return edge;
+
+    const baseStyle = { strokeDasharray: '6 4' };
+    if (!branches.has(data.switchId)) {
+      return { ...edge, animated: false, style: baseStyle };
+    }
+
+    const taken = branches.get(data.switchId) === data.target;
+    return {
+      ...edge,
+      animated: taken,
+      style: taken
+        ? { ...baseStyle, stroke: '#28a745', strokeWidth: 2 }
+        : { ...baseStyle, opacity: 0.3 },
+    };
+  });
+}
+
+export function FlowCanvas({
+  flow,
+  onNodeSelect,
+  showStartEndNode = false,
+  executionResult,
+}: FlowCanvasProps) {
   const [nodes, setNodes, onNodesChange] = useNodesState<Node>([]);
   const [edges, setEdges, onEdgesChange] = useEdgesState<Edge>([]);
 
@@ -344,6 +444,11 @@ export function FlowCanvas({ flow, onNodeSelect, showStartEndNode = false }: Flo
     setEdges(newEdges);
   }, [flow, showStartEndNode, setNodes, setEdges]);
 
+  // Show which switch branches were taken once results are available
+  useEffect(() => {
+    setEdges((currentEdges) => applyBranchHighlights(currentEdges, executionResult));
+  }, [executionResult, setEdges]);
+
   // Handle node changes and save positions when nodes are dragged
   const handleNodesChange = useCallback(
     (changes: NodeChange[]) => {
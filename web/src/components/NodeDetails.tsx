@@ -2,15 +2,21 @@ import type { FlowNode } from '../types/flow';
 
 interface NodeDetailsProps {
   node: FlowNode;
+  onRerun?: () => void; // Re-runs the flow from this node, reusing the latest result
 }
 
-export function NodeDetails({ node }: NodeDetailsProps) {
+export function NodeDetails({ node, onRerun }: NodeDetailsProps) {
   return (
     <div className="node-details">
       <h3>Node: {node.id}</h3>
+      {onRerun && (
+        <button className="btn" onClick={onRerun}>
+          Re-run from here
+        </button>
+      )}
       <div className="detail-row">
         <span className="detail-label">Type:</span>
-        <span className="detail-value">{node.type}</span>
+        <span className="detail-value">{node.type || 'llm'}</span>
       </div>
       {node.provider && (
         <div className="detail-row">
@@ -18,6 +24,18 @@ export function NodeDetails({ node }: NodeDetailsProps) {
           <span className="detail-value">{node.provider}</span>
         </div>
       )}
+      {node.subflow && (
+        <div className="detail-row">
+          <span className="detail-label">Subflow:</span>
+          <span className="detail-value">{node.subflow.path}</span>
+        </div>
+      )}
+      {node.when && (
+        <div className="detail-row">
+          <span className="detail-label">When:</span>
+          <span className="detail-value">{node.when}</span>
+        </div>
+      )}
       {node.model && (
         <div className="detail-row">
           <span className="detail-label">Model:</span>
@@ -32,10 +50,29 @@ export function NodeDetails({ node }: NodeDetailsProps) {
         <span className="detail-label">Outputs:</span>
         <span className="detail-value">{node.outputs.length}</span>
       </div>
-      {node.prompt && (
+      {(node.prompt || node.resolved_prompt) && (
+        <div style={{ marginTop: '0.75rem' }}>
+          <div className="detail-label" style={{ marginBottom: '0.5rem' }}>
+            Prompt{node.prompt_file && ` (${node.prompt_file})`}:
+          </div>
+          <pre
+            style={{
+              fontSize: '0.75rem',
+              backgroundColor: '#fff',
+              padding: '0.5rem',
+              borderRadius: '4px',
+              overflow: 'auto',
+              maxHeight: '150px',
+            }}
+          >
+            {node.prompt_file ? node.resolved_prompt : node.prompt}
+          </pre>
+        </div>
+      )}
+      {node.messages && node.messages.length > 0 && (
         <div style={{ marginTop: '0.75rem' }}>
           <div className="detail-label" style={{ marginBottom: '0.5rem' }}>
-            Prompt:
+            Messages:
           </div>
           <pre
             style={{
@@ -47,7 +84,7 @@ export function NodeDetails({ node }: NodeDetailsProps) {
               maxHeight: '150px',
             }}
           >
-            {node.prompt}
+            {node.messages.map((m) => `${m.role}: ${m.content}`).join('\n\n')}
           </pre>
         </div>
       )}
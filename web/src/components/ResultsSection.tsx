@@ -14,6 +14,17 @@ export function ResultsSection({ result }: ResultsSectionProps) {
         <div className="error">✗ Execution failed: {result.error}</div>
       )}
 
+      {result.errors && result.errors.length > 0 && (
+        <div className="info-item">
+          <div className="info-label">Failed nodes</div>
+          {result.errors.map((nodeError) => (
+            <div key={nodeError.node_id} className="error">
+              {nodeError.node_id}: {nodeError.error}
+            </div>
+          ))}
+        </div>
+      )}
+
       <div className="info-item">
         <div className="info-label">Duration</div>
         <div className="info-value">
@@ -24,7 +35,45 @@ export function ResultsSection({ result }: ResultsSectionProps) {
       {result.node_results &&
         result.node_results.map((nodeResult, idx) => (
           <div key={idx} className="result-item">
-            <h4>Node: {nodeResult.node_id}</h4>
+            <h4>
+              Node: {nodeResult.node_id}
+              {nodeResult.reused && ' (reused)'}
+            </h4>
+            {nodeResult.status === 'skipped' && (
+              <div className="info-message">Skipped: {nodeResult.skip_reason}</div>
+            )}
+            {nodeResult.handled_by && (
+              <div className="info-message">
+                Failed: {nodeResult.error} (handled by {nodeResult.handled_by})
+              </div>
+            )}
+            {nodeResult.provider && (
+              <div className="metric">
+                <span className="metric-label">Answered by: </span>
+                <span className="metric-value">
+                  {nodeResult.provider}/{nodeResult.model}
+                  {nodeResult.cached && ' (cached)'}
+                </span>
+              </div>
+            )}
+            {nodeResult.throttled !== undefined && nodeResult.throttled >= 1000000 && (
+              <div className="metric">
+                <span className="metric-label">Throttled: </span>
+                <span className="metric-value">
+                  {(nodeResult.throttled / 1000000).toFixed(0)}ms
+                </span>
+              </div>
+            )}
+            {nodeResult.violations && nodeResult.violations.length > 0 && (
+              <div>
+                <div className="detail-label">Schema violations:</div>
+                {nodeResult.violations.map((violation) => (
+                  <div key={violation} className="error">
+                    {violation}
+                  </div>
+                ))}
+              </div>
+            )}
             {nodeResult.outputs &&
               Object.entries(nodeResult.outputs).map(([key, value]) => (
                 <div key={key}>
@@ -36,6 +85,17 @@ export function ResultsSection({ result }: ResultsSectionProps) {
                   </pre>
                 </div>
               ))}
+            {nodeResult.items && nodeResult.items.length > 0 && (
+              <div>
+                <div className="detail-label">Items:</div>
+                {nodeResult.items.map((item) => (
+                  <div key={item.node_id} className="metric">
+                    {item.node_id}: {item.status}
+                    {item.error && ` (${item.error})`}
+                  </div>
+                ))}
+              </div>
+            )}
             <div className="metrics">
               <div className="metric">
                 <span className="metric-label">Tokens: </span>
@@ -1,4 +1,4 @@
-import type { Flow, FlowNode, ExecutionResult } from '../types/flow';
+import type { Flow, FlowNode, FlowInput, ExecutionResult } from '../types/flow';
 import { FlowInfo } from './FlowInfo';
 import { NodeDetails } from './NodeDetails';
 import { TestSection } from './TestSection';
@@ -8,11 +8,11 @@ interface SidebarProps {
   flow: Flow | null;
   selectedNode: FlowNode | null;
   inputs: Record<string, string>;
-  rootInputs: string[];
+  rootInputs: FlowInput[];
   executing: boolean;
   executionResult: ExecutionResult | null;
   onInputChange: (key: string, value: string) => void;
-  onExecute: () => void;
+  onExecute: (startAt?: string) => void;
 }
 
 export function Sidebar({
@@ -29,14 +29,21 @@ export function Sidebar({
     <aside className="sidebar">
       {flow && <FlowInfo flow={flow} />}
 
-      {selectedNode && <NodeDetails node={selectedNode} />}
+      {selectedNode && (
+        <NodeDetails
+          node={selectedNode}
+          onRerun={
+            executionResult && !executing ? () => onExecute(selectedNode.id) : undefined
+          }
+        />
+      )}
 
       <TestSection
         inputs={inputs}
         rootInputs={rootInputs}
         executing={executing}
         onInputChange={onInputChange}
-        onExecute={onExecute}
+        onExecute={() => onExecute()}
       />
 
       {executionResult && <ResultsSection result={executionResult} />}
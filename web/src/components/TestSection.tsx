@@ -1,8 +1,9 @@
 import type { ChangeEvent } from 'react';
+import type { FlowInput } from '../types/flow';
 
 interface TestSectionProps {
   inputs: Record<string, string>;
-  rootInputs: string[];
+  rootInputs: FlowInput[];
   executing: boolean;
   onInputChange: (key: string, value: string) => void;
   onExecute: () => void;
@@ -25,14 +26,23 @@ export function TestSection({
       {rootInputs.length === 0 ? (
         <div className="info-message">No inputs required for this flow</div>
       ) : (
-        rootInputs.map(inputName => (
-          <div key={inputName} className="input-group">
-            <label htmlFor={`input-${inputName}`}>Input ({inputName})</label>
+        rootInputs.map(input => (
+          <div key={input.name} className="input-group">
+            <label htmlFor={`input-${input.name}`}>
+              Input ({input.name}
+              {input.type && input.type !== 'string' && `: ${input.type}`}
+              {input.required && ', required'})
+            </label>
+            {input.description && <div className="info-message">{input.description}</div>}
             <textarea
-              id={`input-${inputName}`}
-              value={inputs[inputName] || ''}
-              onChange={handleChange(inputName)}
-              placeholder={`Enter ${inputName}...`}
+              id={`input-${input.name}`}
+              value={inputs[input.name] || ''}
+              onChange={handleChange(input.name)}
+              placeholder={
+                input.default !== undefined
+                  ? `Default: ${typeof input.default === 'string' ? input.default : JSON.stringify(input.default)}`
+                  : `Enter ${input.name}...`
+              }
             />
           </div>
         ))
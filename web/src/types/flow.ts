@@ -1,6 +1,16 @@
 export interface FlowConfig {
   default_provider?: string;
   default_model?: string;
+  retry?: RetryPolicy;
+  timeout?: string;
+  on_error?: 'fail' | 'continue';
+  budget?: Budget;
+}
+
+export interface Budget {
+  max_cost?: number;
+  max_input_tokens?: number;
+  max_output_tokens?: number;
 }
 
 export interface NodeInput {
@@ -11,6 +21,7 @@ export interface NodeInput {
 export interface NodeOutput {
   name: string;
   to?: string;
+  default?: unknown;
 }
 
 export interface NodeSettings {
@@ -19,15 +30,83 @@ export interface NodeSettings {
   [key: string]: unknown;
 }
 
+export interface HTTPConfig {
+  method?: string;
+  url: string;
+  headers?: Record<string, string>;
+  body?: string;
+  timeout?: string;
+  retries?: number;
+  retry_delay?: string;
+  extract?: Record<string, string>;
+}
+
+export interface SwitchConfig {
+  value?: string;
+  cases: Record<string, string>;
+  default?: string;
+}
+
+export interface MapConfig {
+  items?: string;
+  as?: string;
+  max_concurrency?: number;
+  node: FlowNode;
+}
+
+export interface RetryPolicy {
+  max_attempts?: number;
+  backoff?: string;
+  max_backoff?: string;
+  multiplier?: number;
+  jitter?: number;
+  retry_on?: string[];
+  attempt_timeout?: string;
+}
+
+export interface Fallback {
+  provider?: string;
+  model?: string;
+  settings?: NodeSettings;
+}
+
 export interface FlowNode {
   id: string;
-  type: string;
+  type?: string;
   provider?: string;
   model?: string;
   inputs: NodeInput[];
   outputs: NodeOutput[];
   prompt?: string;
+  prompt_file?: string; // read instead of prompt when set
+  resolved_prompt?: string; // contents of prompt_file, filled in by the server
+  messages?: ChatMessage[]; // sent instead of prompt when set
+  output_mode?: 'text' | 'json';
+  schema?: string | Record<string, unknown>; // inline JSON Schema or a path to a schema file
+  repairs?: number;
+  when?: string;
   settings?: NodeSettings;
+  http?: HTTPConfig;
+  switch?: SwitchConfig;
+  map?: MapConfig;
+  subflow?: { path: string };
+  retry?: RetryPolicy;
+  fallbacks?: Fallback[];
+  timeout?: string;
+  on_error?: string; // 'fail', 'continue' or the ID of an error handler node
+}
+
+export interface ChatMessage {
+  role: 'system' | 'user' | 'assistant';
+  content: string;
+}
+
+export interface FlowInput {
+  name: string;
+  type?: 'string' | 'number' | 'bool' | 'list' | 'object';
+  description?: string;
+  default?: unknown;
+  required?: boolean;
 }
 
 export interface Flow {
@@ -35,6 +114,8 @@ export interface Flow {
   name: string;
   description: string;
   config?: FlowConfig;
+  inputs?: FlowInput[];
+  partials?: string; // directory of templates prompts can include
   nodes: FlowNode[];
 }
 
@@ -44,24 +125,59 @@ export interface NodeMetrics {
   duration?: number;
 }
 
+export type NodeStatus = 'succeeded' | 'failed' | 'skipped';
+
+export interface Attempt {
+  number: number;
+  provider: string;
+  model: string;
+  error?: string;
+  error_class?: string;
+  start_time: string;
+  latency: number;
+}
+
 export interface NodeResult {
   node_id: string;
+  status?: NodeStatus;
+  skip_reason?: string;
+  branch?: string;
+  items?: NodeResult[];
+  subflow?: ExecutionResult;
+  handled_by?: string;
+  reused?: boolean;
+  cached?: boolean;
+  attempts?: Attempt[];
+  throttled?: number;
+  provider?: string;
+  model?: string;
   outputs?: Record<string, unknown>;
+  raw_output?: string;
+  violations?: string[];
   metrics?: NodeMetrics;
   error?: string;
 }
 
+export interface NodeError {
+  node_id: string;
+  error: string;
+}
+
 export interface ExecutionResult {
   success: boolean;
   error?: string;
   duration: number;
+  inputs?: Record<string, unknown>;
   node_results?: NodeResult[];
+  errors?: NodeError[];
   outputs?: Record<string, unknown>;
 }
 
 export interface ExecuteFlowRequest {
   flow: Flow;
   inputs: Record<string, unknown>;
+  previous?: ExecutionResult;
+  start_at?: string;
 }
 
 export interface ValidateFlowResponse {